@@ -0,0 +1,323 @@
+// ================================================
+// TRADE-TAPE OHLCV AGGREGATOR
+// ================================================
+// Consumes Polygon trade ticks and rolls them up into
+// configurable-interval OHLCV bars (klines), broadcast
+// over "aggregates.custom" and served via /api/klines.
+// ================================================
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"nexuralflow/websocket-server/fixedpoint"
+)
+
+// supportedIntervals maps the interval strings accepted over the wire/REST
+// API to their bucket duration.
+var supportedIntervals = map[string]time.Duration{
+	"5s":  5 * time.Second,
+	"15s": 15 * time.Second,
+	"1m":  time.Minute,
+	"5m":  5 * time.Minute,
+	"1h":  time.Hour,
+}
+
+const klineRingSize = 500
+
+// Kline is a single completed OHLCV bar.
+type Kline struct {
+	Symbol    string           `json:"symbol"`
+	Interval  string           `json:"interval"`
+	OpenTime  int64            `json:"openTime"`
+	CloseTime int64            `json:"closeTime"`
+	Open      fixedpoint.Value `json:"open"`
+	High      fixedpoint.Value `json:"high"`
+	Low       fixedpoint.Value `json:"low"`
+	Close     fixedpoint.Value `json:"close"`
+	Volume    fixedpoint.Value `json:"volume"`
+	VWAP      fixedpoint.Value `json:"vwap"`
+	Trades    int64            `json:"trades"`
+}
+
+// bucket accumulates trades for one (symbol, interval) until its boundary
+// crosses, at which point it is flushed into a Kline and reset.
+type bucket struct {
+	mu          sync.Mutex
+	openTime    int64
+	open        fixedpoint.Value
+	high        fixedpoint.Value
+	low         fixedpoint.Value
+	close       fixedpoint.Value
+	volume      fixedpoint.Value
+	notional    fixedpoint.Value // running sum of price*size, used to compute VWAP
+	trades      int64
+	initialized bool
+}
+
+// reset clears the bucket so the next trade starts a fresh bar at openTime.
+func (b *bucket) reset(openTime int64) {
+	b.openTime = openTime
+	b.open = fixedpoint.Zero
+	b.high = fixedpoint.Zero
+	b.low = fixedpoint.Zero
+	b.close = fixedpoint.Zero
+	b.volume = fixedpoint.Zero
+	b.notional = fixedpoint.Zero
+	b.trades = 0
+	b.initialized = false
+}
+
+// apply folds one trade into the bucket.
+func (b *bucket) apply(price, size fixedpoint.Value) {
+	if !b.initialized {
+		b.open = price
+		b.high = price
+		b.low = price
+		b.initialized = true
+	} else {
+		if price.GreaterThan(b.high) {
+			b.high = price
+		}
+		if price.LessThan(b.low) {
+			b.low = price
+		}
+	}
+	b.close = price
+	b.volume = b.volume.Add(size)
+	b.notional = b.notional.Add(price.Mul(size))
+	b.trades++
+}
+
+// snapshot returns the completed Kline for this bucket.
+func (b *bucket) snapshot(symbol, interval string, closeTime int64) Kline {
+	vwap := fixedpoint.Zero
+	if !b.volume.IsZero() {
+		vwap = b.notional.Div(b.volume)
+	}
+	return Kline{
+		Symbol:    symbol,
+		Interval:  interval,
+		OpenTime:  b.openTime,
+		CloseTime: closeTime,
+		Open:      b.open,
+		High:      b.high,
+		Low:       b.low,
+		Close:     b.close,
+		Volume:    b.volume,
+		VWAP:      vwap,
+		Trades:    b.trades,
+	}
+}
+
+// ring is a fixed-size circular buffer of completed klines for one
+// (symbol, interval) pair.
+type ring struct {
+	mu   sync.RWMutex
+	data []Kline
+	next int
+	size int
+}
+
+func newRing(capacity int) *ring {
+	return &ring{data: make([]Kline, capacity)}
+}
+
+func (r *ring) push(k Kline) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.data[r.next] = k
+	r.next = (r.next + 1) % len(r.data)
+	if r.size < len(r.data) {
+		r.size++
+	}
+}
+
+// last returns up to n most recent klines, oldest first.
+func (r *ring) last(n int) []Kline {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if n > r.size {
+		n = r.size
+	}
+	out := make([]Kline, 0, n)
+	start := (r.next - n + len(r.data)) % len(r.data)
+	for i := 0; i < n; i++ {
+		out = append(out, r.data[(start+i)%len(r.data)])
+	}
+	return out
+}
+
+// KlineAggregator maintains in-memory OHLCV bars for every (symbol, interval)
+// it has seen a trade for, flushing completed bars on a ticker.
+type KlineAggregator struct {
+	mu      sync.RWMutex
+	buckets map[string]*bucket // key: symbol|interval
+	rings   map[string]*ring   // key: symbol|interval
+	onFlush func(Kline)
+}
+
+// NewKlineAggregator creates an aggregator that invokes onFlush with each
+// completed bar (used to broadcast over "aggregates.custom").
+func NewKlineAggregator(onFlush func(Kline)) *KlineAggregator {
+	a := &KlineAggregator{
+		buckets: make(map[string]*bucket),
+		rings:   make(map[string]*ring),
+		onFlush: onFlush,
+	}
+	go a.flushLoop()
+	return a
+}
+
+func klineKey(symbol, interval string) string {
+	return symbol + "|" + interval
+}
+
+// Ingest folds a trade tick into every configured interval's current bucket
+// for symbol, creating buckets lazily on first sight of the symbol.
+func (a *KlineAggregator) Ingest(symbol string, price, size fixedpoint.Value, tradeTime time.Time) {
+	if symbol == "" || size.Float64() <= 0 {
+		return
+	}
+
+	for interval, duration := range supportedIntervals {
+		key := klineKey(symbol, interval)
+
+		a.mu.Lock()
+		b, ok := a.buckets[key]
+		if !ok {
+			b = &bucket{}
+			a.buckets[key] = b
+			a.rings[key] = newRing(klineRingSize)
+		}
+		a.mu.Unlock()
+
+		openTime := tradeTime.Truncate(duration).UnixMilli()
+
+		b.mu.Lock()
+		if b.openTime != openTime {
+			a.flushBucketLocked(symbol, interval, b, duration)
+			b.reset(openTime)
+		}
+		b.apply(price, size)
+		b.mu.Unlock()
+	}
+}
+
+// flushBucketLocked emits the current contents of b as a completed Kline.
+// Caller must hold b.mu.
+func (a *KlineAggregator) flushBucketLocked(symbol, interval string, b *bucket, duration time.Duration) {
+	if !b.initialized {
+		return
+	}
+
+	closeTime := b.openTime + duration.Milliseconds()
+	kline := b.snapshot(symbol, interval, closeTime)
+
+	a.mu.RLock()
+	r := a.rings[klineKey(symbol, interval)]
+	a.mu.RUnlock()
+	if r != nil {
+		r.push(kline)
+	}
+
+	if a.onFlush != nil {
+		a.onFlush(kline)
+	}
+}
+
+// flushLoop periodically flushes buckets whose interval boundary has passed
+// even for quiet symbols that haven't traded since the boundary crossed.
+func (a *KlineAggregator) flushLoop() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+
+		a.mu.RLock()
+		keys := make([]string, 0, len(a.buckets))
+		for key := range a.buckets {
+			keys = append(keys, key)
+		}
+		a.mu.RUnlock()
+
+		for _, key := range keys {
+			symbol, interval, duration, ok := splitKlineKey(key)
+			if !ok {
+				continue
+			}
+
+			a.mu.RLock()
+			b := a.buckets[key]
+			a.mu.RUnlock()
+			if b == nil {
+				continue
+			}
+
+			currentOpen := now.Truncate(duration).UnixMilli()
+
+			b.mu.Lock()
+			if b.initialized && b.openTime != currentOpen {
+				a.flushBucketLocked(symbol, interval, b, duration)
+				b.reset(currentOpen)
+			}
+			b.mu.Unlock()
+		}
+	}
+}
+
+func splitKlineKey(key string) (symbol, interval string, duration time.Duration, ok bool) {
+	for i := len(key) - 1; i >= 0; i-- {
+		if key[i] == '|' {
+			symbol, interval = key[:i], key[i+1:]
+			duration, ok = supportedIntervals[interval]
+			return
+		}
+	}
+	return "", "", 0, false
+}
+
+// Klines returns up to limit of the most recent completed bars for
+// symbol/interval, oldest first.
+func (a *KlineAggregator) Klines(symbol, interval string, limit int) ([]Kline, error) {
+	if _, ok := supportedIntervals[interval]; !ok {
+		return nil, fmt.Errorf("unsupported interval: %s", interval)
+	}
+
+	a.mu.RLock()
+	r, ok := a.rings[klineKey(symbol, interval)]
+	a.mu.RUnlock()
+	if !ok {
+		return []Kline{}, nil
+	}
+
+	return r.last(limit), nil
+}
+
+// klineAggregator is the process-wide aggregator, wired up in initPolygon.
+var klineAggregator *KlineAggregator
+
+// initKlineAggregator starts the aggregator and wires its flush callback to
+// broadcast completed bars over the "aggregates.custom" channel.
+func initKlineAggregator() {
+	klineAggregator = NewKlineAggregator(func(k Kline) {
+		broadcastMessage(Message{
+			Type:      "market-data",
+			Channel:   "aggregates.custom",
+			Data:      k,
+			Timestamp: time.Now().UnixMilli(),
+			Symbols:   []string{k.Symbol},
+			Metadata: map[string]interface{}{
+				"interval": k.Interval,
+			},
+		})
+	})
+	log.Println("✅ Kline aggregator started")
+}
@@ -14,7 +14,10 @@ import (
 	"math"
 	"math/rand"
 	"net/http"
+	"strconv"
 	"time"
+
+	"nexuralflow/websocket-server/fixedpoint"
 )
 
 // ================================================
@@ -23,23 +26,23 @@ import (
 
 // PortfolioSummary represents portfolio overview
 type PortfolioSummary struct {
-	Value            float64 `json:"value"`
-	DayChange        float64 `json:"dayChange"`
-	DayChangePercent float64 `json:"dayChangePercent"`
-	Positions        int     `json:"positions"`
-	Alerts           int     `json:"alerts"`
-	BuyingPower      float64 `json:"buyingPower"`
+	Value            fixedpoint.Value `json:"value"`
+	DayChange        fixedpoint.Value `json:"dayChange"`
+	DayChangePercent fixedpoint.Value `json:"dayChangePercent"`
+	Positions        int              `json:"positions"`
+	Alerts           int              `json:"alerts"`
+	BuyingPower      fixedpoint.Value `json:"buyingPower"`
 }
 
 // WatchlistStock represents a stock in watchlist
 type WatchlistStock struct {
-	Symbol       string           `json:"symbol"`
-	Name         string           `json:"name"`
-	Price        float64          `json:"price"`
-	Change       float64          `json:"change"`
-	ChangePercent float64         `json:"changePercent"`
-	IntradayData []float64        `json:"intradayData"`
-	Metrics      StockMetrics     `json:"metrics"`
+	Symbol        string             `json:"symbol"`
+	Name          string             `json:"name"`
+	Price         fixedpoint.Value   `json:"price"`
+	Change        fixedpoint.Value   `json:"change"`
+	ChangePercent fixedpoint.Value   `json:"changePercent"`
+	IntradayData  []fixedpoint.Value `json:"intradayData"`
+	Metrics       StockMetrics       `json:"metrics"`
 }
 
 // StockMetrics represents additional stock metrics
@@ -70,8 +73,8 @@ type MarketIndices struct {
 
 // IndexData represents individual index data
 type IndexData struct {
-	Value         float64 `json:"value"`
-	ChangePercent float64 `json:"changePercent"`
+	Value         fixedpoint.Value `json:"value"`
+	ChangePercent fixedpoint.Value `json:"changePercent"`
 }
 
 // MarketBreadth represents market breadth indicators
@@ -99,6 +102,52 @@ type CriticalAlert struct {
 	Time     string `json:"time"`
 }
 
+// ================================================
+// RESPONSE ENVELOPE
+// ================================================
+// Every REST response is wrapped in a Bybit/Binance-style
+// envelope so clients can branch on retCode uniformly.
+// ================================================
+
+// Envelope is the standard wrapper for every REST API response.
+type Envelope struct {
+	RetCode    int                    `json:"retCode"`
+	RetMsg     string                 `json:"retMsg"`
+	Result     interface{}            `json:"result"`
+	RetExtInfo map[string]interface{} `json:"retExtInfo"`
+	Time       int64                  `json:"time"`
+}
+
+const (
+	RetCodeOK = 0
+)
+
+// respondEnvelope wraps result in the standard Envelope and writes it as JSON.
+func respondEnvelope(w http.ResponseWriter, result interface{}) {
+	respondJSON(w, Envelope{
+		RetCode:    RetCodeOK,
+		RetMsg:     "OK",
+		Result:     result,
+		RetExtInfo: map[string]interface{}{},
+		Time:       time.Now().UnixMilli(),
+	})
+}
+
+// withAPIMiddleware chains signature verification and rate limiting ahead of a handler.
+func withAPIMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	limited := apiLimiter.Middleware(rateLimitKey, next)
+	return apiVerifier.Middleware(limited)
+}
+
+// rateLimitKey keys the rate limiter off the authenticated API key, falling
+// back to the remote address for unauthenticated (e.g. OPTIONS) requests.
+func rateLimitKey(r *http.Request) string {
+	if key := r.Header.Get("X-NF-Authenticated-Key"); key != "" {
+		return key
+	}
+	return r.RemoteAddr
+}
+
 // ================================================
 // CACHE KEYS
 // ================================================
@@ -109,8 +158,37 @@ const (
 	cacheKeyMarketPulse = "api:market:pulse"
 	cacheKeySnapshot  = "api:portfolio:snapshot"
 	cacheKeyOpportunities = "api:opportunities:today"
-	
-	cacheTTL = 60 // seconds
+
+	// cacheFresh/cacheStale bound the SWR window for dashboard keys: a hit is
+	// served with no refresh for cacheFresh, then served stale (triggering a
+	// background refresh) for up to cacheStale longer before it expires.
+	cacheFresh = 60 * time.Second
+	cacheStale = 5 * time.Minute
+)
+
+// watchlistSymbols is the fixed symbol set backing generateWatchlist, also
+// used to decide which Polygon trade ticks should invalidate the dashboard
+// cache.
+var watchlistSymbols = []string{"AAPL", "TSLA", "NVDA", "MSFT", "GOOGL", "AMZN", "META", "AMD"}
+
+// isWatchlistSymbol reports whether symbol is one of the fixed symbols that
+// feed the watchlist/market-pulse dashboard endpoints.
+func isWatchlistSymbol(symbol string) bool {
+	for _, s := range watchlistSymbols {
+		if s == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	portfolioCache     = NewCache[PortfolioSummary](cacheFresh, cacheStale)
+	watchlistCache     = NewCache[[]WatchlistStock](cacheFresh, cacheStale)
+	marketPulseCache   = NewCache[MarketPulse](cacheFresh, cacheStale)
+	snapshotCache      = NewCache[interface{}](cacheFresh, cacheStale)
+	opportunitiesCache = NewCache[interface{}](cacheFresh, cacheStale)
+	klinesCache        = NewCache[[]Kline](cacheFresh, cacheStale)
 )
 
 // ================================================
@@ -124,19 +202,15 @@ func handlePortfolioSummary(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	// Try cache first
-	if cached, err := getFromCache(cacheKeyPortfolio); err == nil && cached != nil {
-		respondJSON(w, cached)
+	summary, err := portfolioCache.Get(cacheKeyPortfolio, func() (PortfolioSummary, error) {
+		return generatePortfolioSummary(), nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	
-	// Generate portfolio summary (would fetch from DB in production)
-	summary := generatePortfolioSummary()
-	
-	// Cache result
-	setCache(cacheKeyPortfolio, summary, cacheTTL)
-	
-	respondJSON(w, summary)
+
+	respondEnvelope(w, summary)
 }
 
 // handleWatchlist returns watchlist stocks
@@ -146,19 +220,15 @@ func handleWatchlist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	// Try cache first
-	if cached, err := getFromCache(cacheKeyWatchlist); err == nil && cached != nil {
-		respondJSON(w, cached)
+	watchlist, err := watchlistCache.Get(cacheKeyWatchlist, func() ([]WatchlistStock, error) {
+		return generateWatchlist(), nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	
-	// Generate watchlist (would fetch from DB + Polygon in production)
-	watchlist := generateWatchlist()
-	
-	// Cache result
-	setCache(cacheKeyWatchlist, watchlist, cacheTTL)
-	
-	respondJSON(w, watchlist)
+
+	respondEnvelope(w, watchlist)
 }
 
 // handleMarketPulse returns complete market overview
@@ -168,19 +238,15 @@ func handleMarketPulse(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	// Try cache first
-	if cached, err := getFromCache(cacheKeyMarketPulse); err == nil && cached != nil {
-		respondJSON(w, cached)
+	pulse, err := marketPulseCache.Get(cacheKeyMarketPulse, func() (MarketPulse, error) {
+		return generateMarketPulse(), nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	
-	// Generate market pulse
-	pulse := generateMarketPulse()
-	
-	// Cache result
-	setCache(cacheKeyMarketPulse, pulse, cacheTTL)
-	
-	respondJSON(w, pulse)
+
+	respondEnvelope(w, pulse)
 }
 
 // handlePortfolioSnapshot returns detailed portfolio snapshot
@@ -190,19 +256,52 @@ func handlePortfolioSnapshot(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	// Try cache first
-	if cached, err := getFromCache(cacheKeySnapshot); err == nil && cached != nil {
-		respondJSON(w, cached)
+	snapshot, err := snapshotCache.Get(cacheKeySnapshot, func() (interface{}, error) {
+		return generatePortfolioSnapshot(), nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	
-	// Generate snapshot
-	snapshot := generatePortfolioSnapshot()
-	
-	// Cache result
-	setCache(cacheKeySnapshot, snapshot, cacheTTL)
-	
-	respondJSON(w, snapshot)
+
+	respondEnvelope(w, snapshot)
+}
+
+// handleKlines returns aggregated OHLCV bars for a symbol/interval, e.g.
+// GET /api/klines?symbol=AAPL&interval=1m&limit=200
+func handleKlines(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w, r)
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	symbol := r.URL.Query().Get("symbol")
+	interval := r.URL.Query().Get("interval")
+	if symbol == "" || interval == "" {
+		http.Error(w, "symbol and interval are required", http.StatusBadRequest)
+		return
+	}
+
+	limit := 200
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+
+	cacheKey := fmt.Sprintf("api:klines:%s:%s:%d", symbol, interval, limit)
+	klines, err := klinesCache.Get(cacheKey, func() ([]Kline, error) {
+		if klineAggregator == nil {
+			return []Kline{}, nil
+		}
+		return klineAggregator.Klines(symbol, interval, limit)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	respondEnvelope(w, klines)
 }
 
 // handleTodaysOpportunities returns today's trade opportunities
@@ -212,19 +311,34 @@ func handleTodaysOpportunities(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	// Try cache first
-	if cached, err := getFromCache(cacheKeyOpportunities); err == nil && cached != nil {
-		respondJSON(w, cached)
+	opportunities, err := opportunitiesCache.Get(cacheKeyOpportunities, func() (interface{}, error) {
+		return generateOpportunities(), nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	
-	// Generate opportunities
-	opportunities := generateOpportunities()
-	
-	// Cache result
-	setCache(cacheKeyOpportunities, opportunities, cacheTTL)
-	
-	respondJSON(w, opportunities)
+
+	respondEnvelope(w, opportunities)
+}
+
+// handleRevokeToken force-closes every open WebSocket connection
+// authenticated with the given JTI and rejects that token on any future
+// connection attempt, e.g. POST /admin/tokens/revoke?jti=...
+func handleRevokeToken(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w, r)
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	jti := r.URL.Query().Get("jti")
+	if jti == "" {
+		http.Error(w, "jti is required", http.StatusBadRequest)
+		return
+	}
+
+	closed := wsRegistry.Revoke(jti)
+	respondEnvelope(w, map[string]interface{}{"jti": jti, "closed": closed})
 }
 
 // ================================================
@@ -233,30 +347,29 @@ func handleTodaysOpportunities(w http.ResponseWriter, r *http.Request) {
 
 func generatePortfolioSummary() PortfolioSummary {
 	return PortfolioSummary{
-		Value:            125430.50,
-		DayChange:        2340.25,
-		DayChangePercent: 1.9,
+		Value:            fixedpoint.FromFloat64(125430.50),
+		DayChange:        fixedpoint.FromFloat64(2340.25),
+		DayChangePercent: fixedpoint.FromFloat64(1.9),
 		Positions:        12,
 		Alerts:           3,
-		BuyingPower:      50000.00,
+		BuyingPower:      fixedpoint.FromFloat64(50000.00),
 	}
 }
 
 func generateWatchlist() []WatchlistStock {
-	symbols := []string{"AAPL", "TSLA", "NVDA", "MSFT", "GOOGL", "AMZN", "META", "AMD"}
-	stocks := make([]WatchlistStock, len(symbols))
-	
-	for i, symbol := range symbols {
+	stocks := make([]WatchlistStock, len(watchlistSymbols))
+
+	for i, symbol := range watchlistSymbols {
 		price := 100.0 + float64(i*50) + rand.Float64()*100
 		change := (rand.Float64() - 0.5) * 10
 		changePercent := (change / price) * 100
-		
+
 		stocks[i] = WatchlistStock{
 			Symbol:        symbol,
 			Name:          getStockName(symbol),
-			Price:         price,
-			Change:        change,
-			ChangePercent: changePercent,
+			Price:         fixedpoint.FromFloat64(price),
+			Change:        fixedpoint.FromFloat64(change),
+			ChangePercent: fixedpoint.FromFloat64(changePercent),
 			IntradayData:  generateIntradayData(price, 80),
 			Metrics: StockMetrics{
 				RelativeVolume: 1.0 + rand.Float64()*1.5,
@@ -265,17 +378,17 @@ func generateWatchlist() []WatchlistStock {
 			},
 		}
 	}
-	
+
 	return stocks
 }
 
 func generateMarketPulse() MarketPulse {
 	return MarketPulse{
 		MarketIndices: MarketIndices{
-			SP500:  IndexData{Value: 5780.50, ChangePercent: 0.5},
-			NASDAQ: IndexData{Value: 18234.30, ChangePercent: 0.8},
-			DOW:    IndexData{Value: 42458.60, ChangePercent: 0.3},
-			VIX:    IndexData{Value: 14.25, ChangePercent: -2.1},
+			SP500:  IndexData{Value: fixedpoint.FromFloat64(5780.50), ChangePercent: fixedpoint.FromFloat64(0.5)},
+			NASDAQ: IndexData{Value: fixedpoint.FromFloat64(18234.30), ChangePercent: fixedpoint.FromFloat64(0.8)},
+			DOW:    IndexData{Value: fixedpoint.FromFloat64(42458.60), ChangePercent: fixedpoint.FromFloat64(0.3)},
+			VIX:    IndexData{Value: fixedpoint.FromFloat64(14.25), ChangePercent: fixedpoint.FromFloat64(-2.1)},
 		},
 		TopGainers:  generateWatchlist()[:3],
 		TopLosers:   generateWatchlist()[3:6],
@@ -301,18 +414,18 @@ func generateSectorETFs() []WatchlistStock {
 		{"XLI", "Industrials"},
 		{"XLY", "Consumer Disc"},
 	}
-	
+
 	stocks := make([]WatchlistStock, len(sectors))
 	for i, sector := range sectors {
 		change := (rand.Float64() - 0.5) * 4
 		price := 100.0 + rand.Float64()*50
-		
+
 		stocks[i] = WatchlistStock{
 			Symbol:        sector.symbol,
 			Name:          sector.name,
-			Price:         price,
-			Change:        change,
-			ChangePercent: (change / price) * 100,
+			Price:         fixedpoint.FromFloat64(price),
+			Change:        fixedpoint.FromFloat64(change),
+			ChangePercent: fixedpoint.FromFloat64((change / price) * 100),
 			IntradayData:  generateIntradayData(price, 40),
 			Metrics: StockMetrics{
 				RelativeVolume: 0.9 + rand.Float64()*0.4,
@@ -321,7 +434,7 @@ func generateSectorETFs() []WatchlistStock {
 			},
 		}
 	}
-	
+
 	return stocks
 }
 
@@ -364,13 +477,13 @@ func generateCriticalAlerts() []CriticalAlert {
 
 func generatePortfolioSnapshot() interface{} {
 	return map[string]interface{}{
-		"totalValue":      125430.50,
-		"dayChange":       2340.25,
-		"weekChange":      5678.90,
-		"monthChange":     12345.67,
-		"positions":       12,
-		"topHoldings":     generateWatchlist()[:3],
-		"recentTrades":    []interface{}{},
+		"totalValue":       fixedpoint.FromFloat64(125430.50),
+		"dayChange":        fixedpoint.FromFloat64(2340.25),
+		"weekChange":       fixedpoint.FromFloat64(5678.90),
+		"monthChange":      fixedpoint.FromFloat64(12345.67),
+		"positions":        12,
+		"topHoldings":      generateWatchlist()[:3],
+		"recentTrades":     []interface{}{},
 		"performanceChart": generateIntradayData(125430.50, 100),
 	}
 }
@@ -390,16 +503,16 @@ func generateOpportunities() interface{} {
 // UTILITY FUNCTIONS
 // ================================================
 
-func generateIntradayData(price float64, points int) []float64 {
-	data := make([]float64, points)
+func generateIntradayData(price float64, points int) []fixedpoint.Value {
+	data := make([]fixedpoint.Value, points)
 	volatility := price * 0.02
-	
+
 	for i := 0; i < points; i++ {
 		noise := (rand.Float64() - 0.5) * volatility
 		trend := math.Sin(float64(i)/10) * volatility * 0.5
-		data[i] = price + noise + trend
+		data[i] = fixedpoint.FromFloat64(price + noise + trend)
 	}
-	
+
 	return data
 }
 
@@ -425,44 +538,6 @@ func getRandomFlow() string {
 	return flows[rand.Intn(len(flows))]
 }
 
-// ================================================
-// CACHE HELPERS
-// ================================================
-
-func getFromCache(key string) (interface{}, error) {
-	if redisClient == nil {
-		return nil, fmt.Errorf("redis not available")
-	}
-	
-	val, err := redisClient.Get(ctx, key).Result()
-	if err != nil {
-		return nil, err
-	}
-	
-	var result interface{}
-	if err := json.Unmarshal([]byte(val), &result); err != nil {
-		return nil, err
-	}
-	
-	return result, nil
-}
-
-func setCache(key string, value interface{}, ttl int) {
-	if redisClient == nil {
-		return
-	}
-	
-	data, err := json.Marshal(value)
-	if err != nil {
-		log.Printf("❌ Failed to marshal cache value: %v", err)
-		return
-	}
-	
-	if err := redisClient.Set(ctx, key, data, time.Duration(ttl)*time.Second).Err(); err != nil {
-		log.Printf("❌ Failed to set cache: %v", err)
-	}
-}
-
 // ================================================
 // HTTP HELPERS
 // ================================================
@@ -0,0 +1,183 @@
+// ================================================
+// HMAC REQUEST SIGNING (Bybit/Binance-style)
+// ================================================
+// Verifies X-BAPI-TIMESTAMP / X-BAPI-API-KEY / X-BAPI-SIGN
+// headers against a per-key secret before a handler runs.
+// ================================================
+
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// envelope mirrors the {retCode, retMsg, result, retExtInfo, time} shape used
+// across the REST API so auth failures look identical to handler responses.
+type envelope struct {
+	RetCode    int                    `json:"retCode"`
+	RetMsg     string                 `json:"retMsg"`
+	Result     interface{}            `json:"result"`
+	RetExtInfo map[string]interface{} `json:"retExtInfo"`
+	Time       int64                  `json:"time"`
+}
+
+// RetCodeAuthFailed is returned in the envelope when signature verification fails.
+const RetCodeAuthFailed = 10003
+
+// RespondUnauthorized writes a 401 response in the standard envelope shape.
+func RespondUnauthorized(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(envelope{
+		RetCode:    RetCodeAuthFailed,
+		RetMsg:     err.Error(),
+		Result:     nil,
+		RetExtInfo: map[string]interface{}{},
+		Time:       time.Now().UnixMilli(),
+	})
+}
+
+const (
+	HeaderTimestamp = "X-BAPI-TIMESTAMP"
+	HeaderAPIKey    = "X-BAPI-API-KEY"
+	HeaderSign      = "X-BAPI-SIGN"
+	HeaderRecvWindow = "X-BAPI-RECV-WINDOW"
+
+	defaultRecvWindow = 5 * time.Second
+)
+
+// KeyStore resolves an API key to its secret.
+type KeyStore interface {
+	Secret(apiKey string) (string, bool)
+}
+
+// EnvKeyStore loads "key:secret" pairs from an env var, e.g.
+// NF_API_KEYS="abc123:supersecret,def456:anothersecret".
+type EnvKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]string
+}
+
+// NewEnvKeyStore builds a KeyStore from the given environment variable.
+func NewEnvKeyStore(envVar string) *EnvKeyStore {
+	store := &EnvKeyStore{keys: make(map[string]string)}
+	raw := os.Getenv(envVar)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		store.keys[parts[0]] = parts[1]
+	}
+	return store
+}
+
+// Secret returns the secret configured for apiKey, if any.
+func (s *EnvKeyStore) Secret(apiKey string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	secret, ok := s.keys[apiKey]
+	return secret, ok
+}
+
+// Verifier checks signed requests against a KeyStore.
+type Verifier struct {
+	Store       KeyStore
+	RecvWindow  time.Duration
+}
+
+// NewVerifier creates a Verifier backed by store.
+func NewVerifier(store KeyStore) *Verifier {
+	return &Verifier{Store: store, RecvWindow: defaultRecvWindow}
+}
+
+// Verify validates the signature on r, returning the authenticated API key.
+func (v *Verifier) Verify(r *http.Request) (string, error) {
+	apiKey := r.Header.Get(HeaderAPIKey)
+	timestamp := r.Header.Get(HeaderTimestamp)
+	signature := r.Header.Get(HeaderSign)
+
+	if apiKey == "" || timestamp == "" || signature == "" {
+		return "", fmt.Errorf("missing signature headers")
+	}
+
+	secret, ok := v.Store.Secret(apiKey)
+	if !ok {
+		return "", fmt.Errorf("unknown api key")
+	}
+
+	tsMillis, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid timestamp: %w", err)
+	}
+
+	// The client-supplied recv-window is never part of the signed payload
+	// (computeSignature below only hashes timestamp+apiKey+query), so it must
+	// never be allowed to widen the window beyond v.RecvWindow - otherwise a
+	// captured request could be replayed indefinitely by resending it with an
+	// inflated X-BAPI-RECV-WINDOW. It can only narrow the window, never widen it.
+	recvWindow := v.RecvWindow
+	if rw := r.Header.Get(HeaderRecvWindow); rw != "" {
+		if ms, err := strconv.ParseInt(rw, 10, 64); err == nil && ms > 0 {
+			if clientWindow := time.Duration(ms) * time.Millisecond; clientWindow < recvWindow {
+				recvWindow = clientWindow
+			}
+		}
+	}
+
+	age := time.Since(time.UnixMilli(tsMillis))
+	if age < 0 {
+		age = -age
+	}
+	if age > recvWindow {
+		return "", fmt.Errorf("timestamp outside recv window")
+	}
+
+	expected := computeSignature(timestamp, apiKey, r.URL.RawQuery, secret)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+		return "", fmt.Errorf("signature mismatch")
+	}
+
+	return apiKey, nil
+}
+
+// computeSignature computes the HMAC-SHA256 signature over timestamp+apiKey+queryString.
+func computeSignature(timestamp, apiKey, query, secret string) string {
+	payload := timestamp + apiKey + query
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Middleware wraps next, rejecting requests that fail signature verification.
+func (v *Verifier) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			next(w, r)
+			return
+		}
+
+		apiKey, err := v.Verify(r)
+		if err != nil {
+			RespondUnauthorized(w, err)
+			return
+		}
+
+		r.Header.Set("X-NF-Authenticated-Key", apiKey)
+		next(w, r)
+	}
+}
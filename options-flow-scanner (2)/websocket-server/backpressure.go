@@ -0,0 +1,302 @@
+// ================================================
+// PER-CLIENT BACKPRESSURE
+// ================================================
+// writePump/sendMessage used to silently drop a message whenever a client's
+// send buffer filled up, which is invisible to operators and produces
+// gappy feeds for high-volume symbols. Every outbound message now goes
+// through a per-client token bucket (bytes/sec + msgs/sec) and a bounded
+// queue that applies one of a few configurable policies once budget runs
+// out or the queue backs up, so one slow socket can't stall the broadcast
+// goroutine and operators can see it happening via /stats.
+// ================================================
+
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// backpressurePolicy controls what happens to a message once a client is
+// over budget or its queue is past the high-water mark.
+type backpressurePolicy string
+
+const (
+	policyDropOldest       backpressurePolicy = "drop-oldest"
+	policyDropNewest       backpressurePolicy = "drop-newest"
+	policyCoalesceBySymbol backpressurePolicy = "coalesce-by-symbol"
+	policyDisconnectAfterN backpressurePolicy = "disconnect-after-N-drops"
+
+	// outboundHighWater bounds the per-client queue depth before the
+	// configured policy kicks in, independent of the write-budget check.
+	outboundHighWater = 200
+)
+
+var (
+	configuredPolicy   = loadBackpressurePolicy()
+	maxDropsBeforeKick = getEnvInt("BACKPRESSURE_MAX_DROPS", 50)
+	bytesPerSecBudget  = getEnvInt("BACKPRESSURE_BYTES_PER_SEC", 5*1024*1024)
+	msgsPerSecBudget   = getEnvInt("BACKPRESSURE_MSGS_PER_SEC", 2000)
+
+	droppedTotal   int64
+	coalescedTotal int64
+	evictionsTotal int64
+)
+
+func loadBackpressurePolicy() backpressurePolicy {
+	switch getEnv("BACKPRESSURE_POLICY", string(policyDropNewest)) {
+	case string(policyDropOldest):
+		return policyDropOldest
+	case string(policyCoalesceBySymbol):
+		return policyCoalesceBySymbol
+	case string(policyDisconnectAfterN):
+		return policyDisconnectAfterN
+	default:
+		return policyDropNewest
+	}
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
+
+// backpressureStats renders the counters surfaced over /stats.
+func backpressureStats() map[string]interface{} {
+	return map[string]interface{}{
+		"policy":          string(configuredPolicy),
+		"dropped_total":   atomic.LoadInt64(&droppedTotal),
+		"coalesced_total": atomic.LoadInt64(&coalescedTotal),
+		"evictions_total": atomic.LoadInt64(&evictionsTotal),
+	}
+}
+
+// ================================================
+// WRITE BUDGET
+// ================================================
+
+// writeBudget is a per-client token bucket over both bytes/sec and
+// msgs/sec; a write only passes once both have spare tokens, so a single
+// high-rate consumer can't monopolize the broadcast goroutine. maxBytes/
+// maxMsgs default to the global env-configured budget but can be narrowed
+// per connection (e.g. to a JWT claim's MaxMsgsPerSec for a lower-tier plan).
+type writeBudget struct {
+	mu         sync.Mutex
+	maxBytes   float64
+	maxMsgs    float64
+	byteTokens float64
+	msgTokens  float64
+	lastRefill int64 // unix nanos
+}
+
+func newWriteBudget() *writeBudget {
+	return newWriteBudgetWithLimits(bytesPerSecBudget, msgsPerSecBudget)
+}
+
+// newWriteBudgetWithLimits creates a writeBudget capped at maxBytesPerSec/
+// maxMsgsPerSec instead of the global defaults.
+func newWriteBudgetWithLimits(maxBytesPerSec, maxMsgsPerSec int) *writeBudget {
+	return &writeBudget{
+		maxBytes:   float64(maxBytesPerSec),
+		maxMsgs:    float64(maxMsgsPerSec),
+		byteTokens: float64(maxBytesPerSec),
+		msgTokens:  float64(maxMsgsPerSec),
+		lastRefill: time.Now().UnixNano(),
+	}
+}
+
+// allow reports whether a write of size bytes fits within budget, consuming
+// tokens if so.
+func (b *writeBudget) allow(size int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now().UnixNano()
+	elapsed := float64(now-b.lastRefill) / 1e9
+	if elapsed > 0 {
+		b.byteTokens = minFloat(b.byteTokens+elapsed*b.maxBytes, b.maxBytes)
+		b.msgTokens = minFloat(b.msgTokens+elapsed*b.maxMsgs, b.maxMsgs)
+		b.lastRefill = now
+	}
+
+	if b.byteTokens < float64(size) || b.msgTokens < 1 {
+		return false
+	}
+
+	b.byteTokens -= float64(size)
+	b.msgTokens--
+	return true
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// ================================================
+// OUTBOUND QUEUE
+// ================================================
+
+// outboundItem is one queued message awaiting delivery, tagged with the
+// symbol it's about (if any) so coalesce-by-symbol can find and replace a
+// stale entry instead of appending a duplicate.
+type outboundItem struct {
+	data   []byte
+	symbol string
+}
+
+// outboundQueue is a bounded, policy-aware replacement for a raw channel of
+// outbound messages. notify wakes writePump whenever an item is queued;
+// done is closed once to signal the connection should close.
+type outboundQueue struct {
+	mu               sync.Mutex
+	items            []outboundItem
+	consecutiveDrops int
+	closed           bool
+
+	notify chan struct{}
+	done   chan struct{}
+}
+
+func newOutboundQueue() *outboundQueue {
+	return &outboundQueue{
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+}
+
+// push enqueues data for delivery. When withinBudget is false, or the queue
+// is already at its high-water mark, the configured backpressure policy
+// applies instead of growing the queue unbounded. Returns true if the
+// client should be disconnected as a result.
+func (q *outboundQueue) push(data []byte, symbol string, withinBudget bool, clientID string) (evict bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return false
+	}
+
+	item := outboundItem{data: data, symbol: symbol}
+
+	if withinBudget && len(q.items) < outboundHighWater {
+		q.items = append(q.items, item)
+		q.consecutiveDrops = 0
+		q.wakeLocked()
+		return false
+	}
+
+	switch configuredPolicy {
+	case policyDropOldest:
+		if len(q.items) > 0 {
+			q.items = q.items[1:]
+		}
+		q.items = append(q.items, item)
+		atomic.AddInt64(&droppedTotal, 1)
+		q.wakeLocked()
+		return false
+
+	case policyCoalesceBySymbol:
+		if symbol != "" {
+			for i := range q.items {
+				if q.items[i].symbol == symbol {
+					q.items[i] = item
+					atomic.AddInt64(&coalescedTotal, 1)
+					q.wakeLocked()
+					return false
+				}
+			}
+		}
+		// No existing entry to coalesce into - fall back to dropping the
+		// oldest queued item so the queue still can't grow unbounded.
+		if len(q.items) > 0 {
+			q.items = q.items[1:]
+		}
+		q.items = append(q.items, item)
+		atomic.AddInt64(&droppedTotal, 1)
+		q.wakeLocked()
+		return false
+
+	case policyDisconnectAfterN:
+		atomic.AddInt64(&droppedTotal, 1)
+		q.consecutiveDrops++
+		if q.consecutiveDrops >= maxDropsBeforeKick {
+			atomic.AddInt64(&evictionsTotal, 1)
+			log.Printf("🔌 Client %s evicted: exceeded %d dropped messages", clientID, maxDropsBeforeKick)
+			return true
+		}
+		return false
+
+	default: // policyDropNewest
+		atomic.AddInt64(&droppedTotal, 1)
+		return false
+	}
+}
+
+func (q *outboundQueue) wakeLocked() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// drain removes and returns every currently-queued item.
+func (q *outboundQueue) drain() []outboundItem {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items := q.items
+	q.items = nil
+	return items
+}
+
+// close marks the queue closed and signals writePump to stop. Safe to call
+// more than once.
+func (q *outboundQueue) close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	q.mu.Unlock()
+	close(q.done)
+}
+
+// enqueue checks data against the client's write budget and queues it,
+// applying the configured backpressure policy if the budget is exhausted
+// or the queue is backed up. Evicts (closes) the connection if the policy
+// calls for it.
+func (c *Client) enqueue(data []byte, symbol string) {
+	withinBudget := c.budget.allow(len(data))
+	if c.queue.push(data, symbol, withinBudget, c.id) {
+		c.transport.Close()
+	}
+}
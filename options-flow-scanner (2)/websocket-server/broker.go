@@ -0,0 +1,332 @@
+// ================================================
+// CLUSTER BROKER
+// ================================================
+// Splits message fan-out from the node's local client bookkeeping, the way
+// Centrifuge splits "node" from "broker": handleMessages no longer delivers
+// to subscriptions directly, it hands the message to a Broker, which is
+// responsible for getting it to every node (including this one) that has a
+// local subscriber. The in-memory Broker keeps today's single-node behavior;
+// the Redis Broker makes it safe to run more than one instance behind a
+// load balancer.
+// ================================================
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// defaultHistorySize bounds how many past messages are retained per channel
+// for clients that reconnect and ask to catch up.
+const defaultHistorySize = 200
+
+// BrokerStats summarizes cluster-wide presence for /stats.
+type BrokerStats struct {
+	Nodes       int            `json:"nodes"`
+	Connections int            `json:"connections"`
+	Channels    map[string]int `json:"channels"`
+}
+
+// Broker decouples message fan-out and channel presence from any single
+// process, so handleMessages and subscribeToPolygon work the same whether
+// the server is running as one instance or a fleet behind a load balancer.
+type Broker interface {
+	// Publish delivers msg to every node (including this one) that has a
+	// local subscriber for msg.Channel, and records it in that channel's
+	// history ring.
+	Publish(msg Message) error
+
+	// Subscribe marks this node as having at least one local subscriber for
+	// channel, so future Publish calls for it reach this node.
+	Subscribe(channel string) error
+
+	// Unsubscribe marks this node as no longer having local subscribers for
+	// channel.
+	Unsubscribe(channel string) error
+
+	// History returns up to limit of the most recent messages published on
+	// channel, oldest first.
+	History(channel string, limit int) ([]Message, error)
+
+	// TrackConnect records that this node has gained a connected client, so
+	// Stats can report a real fleet-wide connection count.
+	TrackConnect() error
+
+	// TrackDisconnect records that this node has lost a connected client.
+	TrackDisconnect() error
+
+	// Stats reports presence across the whole fleet, not just this node.
+	Stats() (BrokerStats, error)
+}
+
+// ================================================
+// IN-MEMORY BROKER (single-node default)
+// ================================================
+
+// InMemoryBroker fans out within the current process only. It's the
+// fallback when Redis isn't configured, and matches the server's original
+// single-node behavior.
+type InMemoryBroker struct {
+	onMessage func(Message)
+
+	mu      sync.Mutex
+	history map[string][]Message
+}
+
+// NewInMemoryBroker returns a Broker that calls onMessage synchronously from
+// Publish - there's only one node, so there's nothing to round-trip through.
+func NewInMemoryBroker(onMessage func(Message)) *InMemoryBroker {
+	return &InMemoryBroker{
+		onMessage: onMessage,
+		history:   make(map[string][]Message),
+	}
+}
+
+func (b *InMemoryBroker) Subscribe(channel string) error   { return nil }
+func (b *InMemoryBroker) Unsubscribe(channel string) error { return nil }
+
+func (b *InMemoryBroker) Publish(msg Message) error {
+	b.mu.Lock()
+	hist := append(b.history[msg.Channel], msg)
+	if len(hist) > defaultHistorySize {
+		hist = hist[len(hist)-defaultHistorySize:]
+	}
+	b.history[msg.Channel] = hist
+	b.mu.Unlock()
+
+	if b.onMessage != nil {
+		b.onMessage(msg)
+	}
+	return nil
+}
+
+func (b *InMemoryBroker) History(channel string, limit int) ([]Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	hist := b.history[channel]
+	if limit > len(hist) {
+		limit = len(hist)
+	}
+	out := make([]Message, limit)
+	copy(out, hist[len(hist)-limit:])
+	return out, nil
+}
+
+func (b *InMemoryBroker) Stats() (BrokerStats, error) {
+	return BrokerStats{Nodes: 1, Connections: getClientCount()}, nil
+}
+
+// TrackConnect and TrackDisconnect are no-ops here - there's only one node,
+// and Stats already reports its connection count directly via getClientCount.
+func (b *InMemoryBroker) TrackConnect() error    { return nil }
+func (b *InMemoryBroker) TrackDisconnect() error { return nil }
+
+// ================================================
+// REDIS BROKER (horizontal scale-out)
+// ================================================
+
+const presenceNodesKey = "presence:nodes"
+const presenceTTL = 90 * time.Second
+
+// RedisBroker fans messages out to every server instance over Redis
+// pub/sub, tracks per-node channel presence in Redis SETs so /stats can sum
+// across the fleet, and keeps a bounded history ring per channel.
+type RedisBroker struct {
+	client      *redis.Client
+	nodeID      string
+	historySize int
+	onMessage   func(Message)
+
+	mu   sync.Mutex
+	subs map[string]*redis.PubSub
+}
+
+// NewRedisBroker returns a Broker backed by client. onMessage is invoked for
+// every message this node receives over Redis pub/sub for a channel it is
+// subscribed to, including messages this same node published.
+func NewRedisBroker(client *redis.Client, onMessage func(Message)) *RedisBroker {
+	return &RedisBroker{
+		client:      client,
+		nodeID:      generateNodeID(),
+		historySize: defaultHistorySize,
+		onMessage:   onMessage,
+		subs:        make(map[string]*redis.PubSub),
+	}
+}
+
+func (b *RedisBroker) presenceKey() string {
+	return "presence:" + b.nodeID
+}
+
+func (b *RedisBroker) connsKey() string {
+	return "presence:conns:" + b.nodeID
+}
+
+// TrackConnect increments this node's connection counter and renews its
+// presence. Stats sums this counter across nodes for a real fleet-wide
+// connection count, instead of the (node, channel) presence pairs channel
+// subscriptions track.
+func (b *RedisBroker) TrackConnect() error {
+	pipe := b.client.Pipeline()
+	pipe.Incr(ctx, b.connsKey())
+	pipe.Expire(ctx, b.connsKey(), presenceTTL)
+	pipe.SAdd(ctx, presenceNodesKey, b.nodeID)
+	pipe.Expire(ctx, presenceNodesKey, presenceTTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// TrackDisconnect decrements this node's connection counter.
+func (b *RedisBroker) TrackDisconnect() error {
+	pipe := b.client.Pipeline()
+	pipe.Decr(ctx, b.connsKey())
+	pipe.Expire(ctx, b.connsKey(), presenceTTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Subscribe registers channel in this node's presence set and, the first
+// time it's asked for, opens a Redis pub/sub subscription that fans
+// incoming messages into onMessage.
+func (b *RedisBroker) Subscribe(channel string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subs[channel]; !ok {
+		pubsub := b.client.Subscribe(ctx, channel)
+		b.subs[channel] = pubsub
+		go b.fanIn(channel, pubsub)
+	}
+
+	return b.touchPresence(channel)
+}
+
+// fanIn delivers every message received on pubsub to onMessage until the
+// subscription is closed by Unsubscribe.
+func (b *RedisBroker) fanIn(channel string, pubsub *redis.PubSub) {
+	for rawMsg := range pubsub.Channel() {
+		var msg Message
+		if err := json.Unmarshal([]byte(rawMsg.Payload), &msg); err != nil {
+			log.Printf("❌ Broker: failed to parse message on %s: %v", channel, err)
+			continue
+		}
+		if b.onMessage != nil {
+			b.onMessage(msg)
+		}
+	}
+}
+
+// touchPresence records that this node currently serves channel, renewing
+// the TTL so a crashed node's presence expires instead of lingering forever.
+func (b *RedisBroker) touchPresence(channel string) error {
+	pipe := b.client.Pipeline()
+	pipe.SAdd(ctx, b.presenceKey(), channel)
+	pipe.Expire(ctx, b.presenceKey(), presenceTTL)
+	pipe.SAdd(ctx, presenceNodesKey, b.nodeID)
+	pipe.Expire(ctx, presenceNodesKey, presenceTTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Unsubscribe removes channel from this node's presence set and closes its
+// pub/sub subscription.
+func (b *RedisBroker) Unsubscribe(channel string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if pubsub, ok := b.subs[channel]; ok {
+		pubsub.Close()
+		delete(b.subs, channel)
+	}
+
+	return b.client.SRem(ctx, b.presenceKey(), channel).Err()
+}
+
+// Publish records msg in channel's history ring and publishes it once -
+// every node subscribed to the channel (including this one, if it has
+// local subscribers) receives it back through fanIn.
+func (b *RedisBroker) Publish(msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	historyKey := "history:" + msg.Channel
+	pipe := b.client.Pipeline()
+	pipe.LPush(ctx, historyKey, data)
+	pipe.LTrim(ctx, historyKey, 0, int64(b.historySize-1))
+	pipe.Expire(ctx, historyKey, 24*time.Hour)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("⚠️  Broker: failed to record history for %s: %v", msg.Channel, err)
+	}
+
+	return b.client.Publish(ctx, msg.Channel, data).Err()
+}
+
+// History returns up to limit of the most recent messages published on
+// channel, oldest first. LPUSH stores newest-first, so the raw LRANGE
+// result is reversed before returning.
+func (b *RedisBroker) History(channel string, limit int) ([]Message, error) {
+	raw, err := b.client.LRange(ctx, "history:"+channel, 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]Message, 0, len(raw))
+	for i := len(raw) - 1; i >= 0; i-- {
+		var msg Message
+		if err := json.Unmarshal([]byte(raw[i]), &msg); err != nil {
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// Stats sums presence across every node that has touched its presence key
+// within presenceTTL, so operators see fleet-wide totals instead of
+// whichever pod happened to answer the request. Connections comes from each
+// node's own connsKey counter (maintained by TrackConnect/TrackDisconnect),
+// not the per-channel presence sets - those count (node, channel) presence
+// pairs, not connected clients.
+func (b *RedisBroker) Stats() (BrokerStats, error) {
+	nodeIDs, err := b.client.SMembers(ctx, presenceNodesKey).Result()
+	if err != nil {
+		return BrokerStats{}, err
+	}
+
+	stats := BrokerStats{Nodes: len(nodeIDs), Channels: make(map[string]int)}
+
+	for _, nodeID := range nodeIDs {
+		channels, err := b.client.SMembers(ctx, "presence:"+nodeID).Result()
+		if err != nil {
+			continue
+		}
+		for _, channel := range channels {
+			stats.Channels[channel]++
+		}
+
+		if conns, err := b.client.Get(ctx, "presence:conns:"+nodeID).Int(); err == nil && conns > 0 {
+			stats.Connections += conns
+		}
+	}
+
+	return stats, nil
+}
+
+// generateNodeID builds a per-process identifier for presence tracking,
+// stable for the life of the process but unique across instances.
+func generateNodeID() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "node"
+	}
+	return hostname + "-" + randString(6)
+}
@@ -0,0 +1,400 @@
+// ================================================
+// PLUGGABLE CACHE BACKEND
+// ================================================
+// cache.go used to talk to *redis.Client directly, which meant there was no
+// way to run the trading helpers (CachePrice, CacheGEX, CacheFlow, ...)
+// against anything else - no in-memory fake for tests, no sharded cluster
+// for scale-out. CacheBackend pulls the storage operations behind an
+// interface, the same way Broker (broker.go) pulled message fan-out behind
+// one: cache.go's helpers call through `cache CacheBackend` instead of a
+// concrete client, and InitDragonfly picks the implementation from config.
+// ================================================
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// CacheBackend is the storage contract the trading cache helpers in cache.go
+// are written against. Values are already-encoded bytes - encoding/decoding
+// stays the caller's responsibility so the backend never needs to know the
+// payload shape.
+type CacheBackend interface {
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Del(ctx context.Context, key string) error
+
+	HSet(ctx context.Context, key, field string, value interface{}) error
+	HMSet(ctx context.Context, key string, values map[string]interface{}, ttl time.Duration) error
+	HGetAll(ctx context.Context, key string) (map[string]string, error)
+
+	LPush(ctx context.Context, key string, value []byte, maxLength int, ttl time.Duration) error
+	LRange(ctx context.Context, key string, start, stop int64) ([]string, error)
+
+	BatchSet(ctx context.Context, keyValues map[string][]byte, ttl time.Duration) error
+
+	Stats(ctx context.Context) (map[string]interface{}, error)
+	Health(ctx context.Context) error
+	Flush(ctx context.Context) error
+	Close() error
+}
+
+// ================================================
+// REDIS BACKEND (single-node and cluster)
+// ================================================
+
+// redisCacheBackend implements CacheBackend on top of redis.UniversalClient,
+// which *redis.Client and *redis.ClusterClient both satisfy - so the same
+// code serves a single DragonflyDB instance or a sharded Redis Cluster, and
+// the only difference is which constructor built the client.
+type redisCacheBackend struct {
+	client redis.UniversalClient
+}
+
+// newRedisCacheBackend wraps an already-connected single-node client.
+func newRedisCacheBackend(client *redis.Client) *redisCacheBackend {
+	return &redisCacheBackend{client: client}
+}
+
+// newRedisClusterCacheBackend wraps an already-connected cluster client.
+func newRedisClusterCacheBackend(client *redis.ClusterClient) *redisCacheBackend {
+	return &redisCacheBackend{client: client}
+}
+
+func (b *redisCacheBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return b.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (b *redisCacheBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	return b.client.Get(ctx, key).Bytes()
+}
+
+func (b *redisCacheBackend) Del(ctx context.Context, key string) error {
+	return b.client.Del(ctx, key).Err()
+}
+
+func (b *redisCacheBackend) HSet(ctx context.Context, key, field string, value interface{}) error {
+	return b.client.HSet(ctx, key, field, value).Err()
+}
+
+func (b *redisCacheBackend) HMSet(ctx context.Context, key string, values map[string]interface{}, ttl time.Duration) error {
+	if err := b.client.HSet(ctx, key, values).Err(); err != nil {
+		return err
+	}
+	return b.client.Expire(ctx, key, ttl).Err()
+}
+
+func (b *redisCacheBackend) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	return b.client.HGetAll(ctx, key).Result()
+}
+
+func (b *redisCacheBackend) LPush(ctx context.Context, key string, value []byte, maxLength int, ttl time.Duration) error {
+	pipe := b.client.Pipeline()
+	pipe.LPush(ctx, key, value)
+	pipe.LTrim(ctx, key, 0, int64(maxLength-1))
+	pipe.Expire(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (b *redisCacheBackend) LRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	return b.client.LRange(ctx, key, start, stop).Result()
+}
+
+func (b *redisCacheBackend) BatchSet(ctx context.Context, keyValues map[string][]byte, ttl time.Duration) error {
+	pipe := b.client.Pipeline()
+	for key, value := range keyValues {
+		pipe.Set(ctx, key, value, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (b *redisCacheBackend) Stats(ctx context.Context) (map[string]interface{}, error) {
+	info, err := b.client.Info(ctx, "memory").Result()
+	if err != nil {
+		return nil, err
+	}
+	dbSize, err := b.client.DBSize(ctx).Result()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"connected": true,
+		"keys":      dbSize,
+		"info":      info,
+	}, nil
+}
+
+func (b *redisCacheBackend) Health(ctx context.Context) error {
+	return b.client.Ping(ctx).Err()
+}
+
+func (b *redisCacheBackend) Flush(ctx context.Context) error {
+	return b.client.FlushAll(ctx).Err()
+}
+
+func (b *redisCacheBackend) Close() error {
+	return b.client.Close()
+}
+
+// ================================================
+// IN-MEMORY BACKEND (tests and local dev)
+// ================================================
+
+// memShard guards one slice of the in-memory keyspace. Sharding spreads lock
+// contention the same way a real cluster spreads keys across nodes.
+const memShardCount = 16
+
+type memEntry struct {
+	value   []byte
+	hash    map[string]string
+	list    [][]byte
+	expires time.Time // zero means no expiry
+}
+
+type memShard struct {
+	mu      sync.Mutex
+	entries map[string]*memEntry
+}
+
+// inMemoryCacheBackend is a pure in-process CacheBackend with a background
+// TTL sweeper, standing in for DragonflyDB in tests and local dev where
+// nobody wants to stand up a real Redis.
+type inMemoryCacheBackend struct {
+	shards [memShardCount]*memShard
+	stop   chan struct{}
+}
+
+func newInMemoryCacheBackend() *inMemoryCacheBackend {
+	b := &inMemoryCacheBackend{stop: make(chan struct{})}
+	for i := range b.shards {
+		b.shards[i] = &memShard{entries: make(map[string]*memEntry)}
+	}
+	go b.sweepLoop()
+	return b
+}
+
+func (b *inMemoryCacheBackend) shardFor(key string) *memShard {
+	var h uint32
+	for i := 0; i < len(key); i++ {
+		h = h*31 + uint32(key[i])
+	}
+	return b.shards[h%memShardCount]
+}
+
+func (b *inMemoryCacheBackend) sweepLoop() {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			for _, shard := range b.shards {
+				shard.mu.Lock()
+				for key, entry := range shard.entries {
+					if !entry.expires.IsZero() && now.After(entry.expires) {
+						delete(shard.entries, key)
+					}
+				}
+				shard.mu.Unlock()
+			}
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+func expiresAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+func (b *inMemoryCacheBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	shard := b.shardFor(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	shard.entries[key] = &memEntry{value: append([]byte(nil), value...), expires: expiresAt(ttl)}
+	return nil
+}
+
+func (b *inMemoryCacheBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	shard := b.shardFor(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	entry, ok := shard.entries[key]
+	if !ok || (!entry.expires.IsZero() && time.Now().After(entry.expires)) {
+		return nil, redis.Nil
+	}
+	return append([]byte(nil), entry.value...), nil
+}
+
+func (b *inMemoryCacheBackend) Del(ctx context.Context, key string) error {
+	shard := b.shardFor(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	delete(shard.entries, key)
+	return nil
+}
+
+func (b *inMemoryCacheBackend) HSet(ctx context.Context, key, field string, value interface{}) error {
+	shard := b.shardFor(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	entry, ok := shard.entries[key]
+	if !ok {
+		entry = &memEntry{hash: make(map[string]string)}
+		shard.entries[key] = entry
+	}
+	if entry.hash == nil {
+		entry.hash = make(map[string]string)
+	}
+	entry.hash[field] = fmt.Sprintf("%v", value)
+	return nil
+}
+
+func (b *inMemoryCacheBackend) HMSet(ctx context.Context, key string, values map[string]interface{}, ttl time.Duration) error {
+	shard := b.shardFor(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	entry, ok := shard.entries[key]
+	if !ok {
+		entry = &memEntry{hash: make(map[string]string)}
+		shard.entries[key] = entry
+	}
+	if entry.hash == nil {
+		entry.hash = make(map[string]string)
+	}
+	for field, value := range values {
+		entry.hash[field] = fmt.Sprintf("%v", value)
+	}
+	entry.expires = expiresAt(ttl)
+	return nil
+}
+
+func (b *inMemoryCacheBackend) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	shard := b.shardFor(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	entry, ok := shard.entries[key]
+	if !ok || (!entry.expires.IsZero() && time.Now().After(entry.expires)) {
+		return map[string]string{}, nil
+	}
+	out := make(map[string]string, len(entry.hash))
+	for field, value := range entry.hash {
+		out[field] = value
+	}
+	return out, nil
+}
+
+func (b *inMemoryCacheBackend) LPush(ctx context.Context, key string, value []byte, maxLength int, ttl time.Duration) error {
+	shard := b.shardFor(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	entry, ok := shard.entries[key]
+	if !ok {
+		entry = &memEntry{}
+		shard.entries[key] = entry
+	}
+	entry.list = append([][]byte{append([]byte(nil), value...)}, entry.list...)
+	if len(entry.list) > maxLength {
+		entry.list = entry.list[:maxLength]
+	}
+	entry.expires = expiresAt(ttl)
+	return nil
+}
+
+func (b *inMemoryCacheBackend) LRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	shard := b.shardFor(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	entry, ok := shard.entries[key]
+	if !ok || (!entry.expires.IsZero() && time.Now().After(entry.expires)) {
+		return []string{}, nil
+	}
+
+	n := int64(len(entry.list))
+	if stop < 0 || stop >= n {
+		stop = n - 1
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > stop || n == 0 {
+		return []string{}, nil
+	}
+
+	out := make([]string, 0, stop-start+1)
+	for i := start; i <= stop; i++ {
+		out = append(out, string(entry.list[i]))
+	}
+	return out, nil
+}
+
+func (b *inMemoryCacheBackend) BatchSet(ctx context.Context, keyValues map[string][]byte, ttl time.Duration) error {
+	for key, value := range keyValues {
+		if err := b.Set(ctx, key, value, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *inMemoryCacheBackend) Stats(ctx context.Context) (map[string]interface{}, error) {
+	var keys int
+	for _, shard := range b.shards {
+		shard.mu.Lock()
+		keys += len(shard.entries)
+		shard.mu.Unlock()
+	}
+	return map[string]interface{}{
+		"connected": true,
+		"keys":      keys,
+		"info":      "in-memory backend - no server stats",
+	}, nil
+}
+
+func (b *inMemoryCacheBackend) Health(ctx context.Context) error {
+	return nil
+}
+
+func (b *inMemoryCacheBackend) Flush(ctx context.Context) error {
+	for _, shard := range b.shards {
+		shard.mu.Lock()
+		shard.entries = make(map[string]*memEntry)
+		shard.mu.Unlock()
+	}
+	return nil
+}
+
+func (b *inMemoryCacheBackend) Close() error {
+	close(b.stop)
+	return nil
+}
+
+// parseClusterAddrs splits a comma-separated DRAGONFLY_CLUSTER_ADDRS value
+// into a sorted, de-duplicated address list.
+func parseClusterAddrs(raw string) []string {
+	seen := make(map[string]bool)
+	var addrs []string
+	for _, addr := range strings.Split(raw, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" || seen[addr] {
+			continue
+		}
+		seen[addr] = true
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
@@ -0,0 +1,136 @@
+// ================================================
+// L1 IN-PROCESS CACHE
+// ================================================
+// CacheGet/CacheSet used to round-trip to the CacheBackend (cache_backend.go)
+// on every call, which is wasted latency when 100+ WebSocket workers all ask
+// for "price:SPY" within the same tick. l1 sits in front of the backend as a
+// bounded, sub-microsecond LRU: CacheGet checks l1 first and only falls
+// through on a miss, CacheSet/CacheDel write through both tiers, and misses
+// are coalesced with singleflight so a thundering herd for the same key
+// produces one backend round-trip instead of one per goroutine. A short-TTL
+// "not found" marker (negative caching) stops a bad symbol from hammering
+// the backend every single call.
+// ================================================
+
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// l1Capacity bounds how many keys l1 holds before evicting the
+// least-recently-used entry; l1TTL/l1NegativeTTL cap how long a positive or
+// negative entry is trusted before a read falls through to the backend again.
+var (
+	l1Capacity    = getEnvInt("CACHE_L1_CAPACITY", 10000)
+	l1TTL         = getEnvDuration("CACHE_L1_TTL", 5*time.Second)
+	l1NegativeTTL = getEnvDuration("CACHE_L1_NEGATIVE_TTL", 2*time.Second)
+)
+
+// cacheSingleflight coalesces concurrent CacheGet misses for the same key
+// into a single backend round-trip.
+var cacheSingleflight singleflight.Group
+
+// l1Hits/l1Misses back GetCacheStats' l1Hits/l1Misses fields.
+var (
+	l1Hits   uint64
+	l1Misses uint64
+)
+
+type l1Entry struct {
+	key      string
+	value    []byte
+	negative bool
+	expires  time.Time
+}
+
+// l1Cache is a bounded, LRU-evicted in-process cache. It's deliberately
+// simple (a map plus a doubly-linked list) rather than a generic container,
+// since it only ever stores the []byte payloads CacheBackend already deals in.
+type l1Cache struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List // front = most recently used
+}
+
+func newL1Cache(capacity int) *l1Cache {
+	return &l1Cache{
+		capacity: capacity,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// get returns (value, negative, found). found is false on a miss or an
+// expired entry; negative is true for a cached "not found" marker.
+func (l *l1Cache) get(key string) ([]byte, bool, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, ok := l.items[key]
+	if !ok {
+		return nil, false, false
+	}
+	entry := elem.Value.(*l1Entry)
+	if time.Now().After(entry.expires) {
+		l.order.Remove(elem)
+		delete(l.items, key)
+		return nil, false, false
+	}
+
+	l.order.MoveToFront(elem)
+	return entry.value, entry.negative, true
+}
+
+func (l *l1Cache) set(key string, value []byte, ttl time.Duration) {
+	l.put(key, &l1Entry{key: key, value: value, expires: time.Now().Add(ttl)})
+}
+
+func (l *l1Cache) setNegative(key string, ttl time.Duration) {
+	l.put(key, &l1Entry{key: key, negative: true, expires: time.Now().Add(ttl)})
+}
+
+func (l *l1Cache) put(key string, entry *l1Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.items[key]; ok {
+		elem.Value = entry
+		l.order.MoveToFront(elem)
+		return
+	}
+
+	l.items[key] = l.order.PushFront(entry)
+	for l.order.Len() > l.capacity {
+		oldest := l.order.Back()
+		if oldest == nil {
+			break
+		}
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*l1Entry).key)
+	}
+}
+
+func (l *l1Cache) del(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.items[key]; ok {
+		l.order.Remove(elem)
+		delete(l.items, key)
+	}
+}
+
+func (l *l1Cache) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+// l1 is the package-wide L1 tier in front of the CacheBackend (`cache`).
+var l1 = newL1Cache(l1Capacity)
@@ -0,0 +1,236 @@
+// ================================================
+// DISTRIBUTED LOCKS AND RATE LIMITS
+// ================================================
+// ratelimit.Limiter (ratelimit/ratelimit.go) is a per-process token bucket -
+// fine for the signed REST API, but no help when the trading pipeline needs
+// "only one worker fetches SPY's option chain per minute across the whole
+// fleet" to stay under Polygon/Alpaca quotas. CacheLock and CacheRateLimit
+// give that fleet-wide coordination, implemented as Lua scripts so the
+// check-and-set is atomic on the backend instead of racing across instances.
+// ================================================
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// CacheScriptBackend is implemented by CacheBackends capable of running Lua
+// scripts and conditional sets - both Redis-backed variants in
+// cache_backend.go. The in-memory backend doesn't implement it; CacheLock
+// and CacheRateLimit error (or fail open) against it, the same
+// optional-interface pattern cache_pubsub.go and cache_stream.go use.
+type CacheScriptBackend interface {
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	EvalInts(ctx context.Context, script string, keys []string, args ...interface{}) ([]int64, error)
+}
+
+func (b *redisCacheBackend) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	return b.client.SetNX(ctx, key, value, ttl).Result()
+}
+
+func (b *redisCacheBackend) EvalInts(ctx context.Context, script string, keys []string, args ...interface{}) ([]int64, error) {
+	res, err := b.client.Eval(ctx, script, keys, args...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := res.(type) {
+	case []interface{}:
+		out := make([]int64, len(v))
+		for i, item := range v {
+			n, ok := item.(int64)
+			if !ok {
+				return nil, fmt.Errorf("cache: unexpected eval element type %T", item)
+			}
+			out[i] = n
+		}
+		return out, nil
+	case int64:
+		return []int64{v}, nil
+	default:
+		return nil, fmt.Errorf("cache: unexpected eval result type %T", res)
+	}
+}
+
+// ================================================
+// DISTRIBUTED LOCK (Redlock-style SET NX PX + fencing token)
+// ================================================
+
+// lockUnlockScript deletes KEYS[1] only if its value still matches the
+// caller's token (ARGV[1]) - the fencing check that stops a holder whose
+// lease already expired, and who's now stalled past it, from deleting a
+// lock some other worker has since legitimately acquired.
+const lockUnlockScript = `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+else
+	return 0
+end
+`
+
+// lockRenewScript extends KEYS[1]'s TTL to ARGV[2] ms, but only if its value
+// still matches the caller's token (ARGV[1]) - same fencing check as
+// lockUnlockScript, applied to renewal instead of release.
+const lockRenewScript = `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+else
+	return 0
+end
+`
+
+// CacheLock acquires a fleet-wide distributed lock on key for ttl, returning
+// an Unlock function. A random fencing token is stored as the lock's value;
+// Unlock (and the automatic lease renewal running in the background) only
+// ever touch the key if its value still matches that token, so a lock this
+// holder no longer owns can't be released or renewed out from under its new
+// owner. The lock renews itself at ttl/3 intervals until Unlock is called,
+// so a legitimately long-running holder doesn't lose it mid-task.
+func CacheLock(key string, ttl time.Duration) (func(), error) {
+	backend, ok := cache.(CacheScriptBackend)
+	if !ok {
+		return nil, fmt.Errorf("cache backend does not support distributed locks")
+	}
+
+	token, err := randomLockToken()
+	if err != nil {
+		return nil, err
+	}
+
+	acquireCtx, cancel := context.WithTimeout(cacheCtx, 2*time.Second)
+	acquired, err := backend.SetNX(acquireCtx, key, token, ttl)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("cache lock: %w", err)
+	}
+	if !acquired {
+		return nil, fmt.Errorf("cache lock: %s is already held", key)
+	}
+
+	renewCtx, stopRenewing := context.WithCancel(context.Background())
+	go renewCacheLock(renewCtx, backend, key, token, ttl)
+
+	var unlockOnce sync.Once
+	return func() {
+		unlockOnce.Do(func() {
+			stopRenewing()
+			ctx, cancel := context.WithTimeout(cacheCtx, 2*time.Second)
+			defer cancel()
+			if _, err := backend.EvalInts(ctx, lockUnlockScript, []string{key}, token); err != nil {
+				log.Printf("⚠️  Cache lock: failed to release %s: %v", key, err)
+			}
+		})
+	}, nil
+}
+
+// renewCacheLock re-extends key's TTL to ttl every ttl/3, stopping once ctx
+// is canceled (CacheLock's Unlock was called) or the lease is lost - e.g.
+// this process stalled long enough for the lock to expire and another
+// worker acquired it first.
+func renewCacheLock(ctx context.Context, backend CacheScriptBackend, key, token string, ttl time.Duration) {
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			renewCtx, cancel := context.WithTimeout(cacheCtx, 2*time.Second)
+			res, err := backend.EvalInts(renewCtx, lockRenewScript, []string{key}, token, ttl.Milliseconds())
+			cancel()
+			if err != nil {
+				log.Printf("⚠️  Cache lock: failed to renew %s: %v", key, err)
+				continue
+			}
+			if len(res) == 0 || res[0] == 0 {
+				log.Printf("⚠️  Cache lock: lost ownership of %s before renewal", key)
+				return
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func randomLockToken() (string, error) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", fmt.Errorf("cache lock: failed to generate fencing token: %w", err)
+	}
+	return hex.EncodeToString(buf[:]), nil
+}
+
+// ================================================
+// DISTRIBUTED SLIDING-WINDOW RATE LIMIT
+// ================================================
+
+// rateLimitScript implements a sliding-window-log limiter on a sorted set:
+// it trims entries older than the window, checks the remaining count
+// against the limit, and - only if under limit - records this request, all
+// atomically so concurrent callers across the fleet can't both observe
+// "under limit" and both proceed. Returns {allowed (0/1), retryAfterMs}.
+const rateLimitScript = `
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local member = ARGV[4]
+
+redis.call("ZREMRANGEBYSCORE", key, "-inf", now - window)
+local count = redis.call("ZCARD", key)
+
+if count < limit then
+	redis.call("ZADD", key, now, member)
+	redis.call("PEXPIRE", key, window)
+	return {1, 0}
+end
+
+local oldest = redis.call("ZRANGE", key, 0, 0, "WITHSCORES")
+local retryAfter = window - (now - tonumber(oldest[2]))
+if retryAfter < 0 then
+	retryAfter = 0
+end
+return {0, retryAfter}
+`
+
+// CacheRateLimit applies a sliding-window rate limit of limit requests per
+// window to key, coordinated cluster-wide through the cache backend -
+// ratelimit.Limiter (ratelimit/ratelimit.go) does the same job per-process
+// with an in-memory token bucket, which can't stop the fleet as a whole from
+// exceeding an upstream API's quota. Fails open (allowed=true) if the active
+// backend can't run the script, since a broken rate limiter shouldn't also
+// take down the feature it's protecting.
+func CacheRateLimit(key string, limit int, window time.Duration) (allowed bool, retryAfter time.Duration) {
+	backend, ok := cache.(CacheScriptBackend)
+	if !ok {
+		return true, 0
+	}
+
+	member, err := randomLockToken()
+	if err != nil {
+		log.Printf("⚠️  Cache rate limit: %v", err)
+		return true, 0
+	}
+
+	ctx, cancel := context.WithTimeout(cacheCtx, 2*time.Second)
+	defer cancel()
+
+	res, err := backend.EvalInts(ctx, rateLimitScript, []string{key}, time.Now().UnixMilli(), window.Milliseconds(), limit, member)
+	if err != nil {
+		log.Printf("⚠️  Cache rate limit: script failed for %s: %v", key, err)
+		return true, 0
+	}
+	if len(res) != 2 {
+		log.Printf("⚠️  Cache rate limit: unexpected script result for %s: %v", key, res)
+		return true, 0
+	}
+
+	return res[0] == 1, time.Duration(res[1]) * time.Millisecond
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeScriptBackend is a minimal in-process CacheScriptBackend that
+// replicates the Lua scripts' semantics in Go, so CacheLock/CacheRateLimit's
+// fencing and sliding-window logic can be exercised without a real Redis.
+type fakeScriptBackend struct {
+	*inMemoryCacheBackend
+
+	mu    sync.Mutex
+	kv    map[string]string
+	zsets map[string]map[string]int64 // rate-limit key -> member -> score(ms)
+}
+
+func newFakeScriptBackend() *fakeScriptBackend {
+	return &fakeScriptBackend{
+		inMemoryCacheBackend: newInMemoryCacheBackend(),
+		kv:                   make(map[string]string),
+		zsets:                make(map[string]map[string]int64),
+	}
+}
+
+func (f *fakeScriptBackend) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, exists := f.kv[key]; exists {
+		return false, nil
+	}
+	f.kv[key] = value
+	return true, nil
+}
+
+func (f *fakeScriptBackend) EvalInts(ctx context.Context, script string, keys []string, args ...interface{}) ([]int64, error) {
+	switch script {
+	case lockUnlockScript:
+		key, token := keys[0], args[0].(string)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.kv[key] != token {
+			return []int64{0}, nil
+		}
+		delete(f.kv, key)
+		return []int64{1}, nil
+
+	case lockRenewScript:
+		key, token := keys[0], args[0].(string)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.kv[key] != token {
+			return []int64{0}, nil
+		}
+		return []int64{1}, nil
+
+	case rateLimitScript:
+		key := keys[0]
+		now, window, limit, member := toInt64(args[0]), toInt64(args[1]), toInt64(args[2]), args[3].(string)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		set := f.zsets[key]
+		if set == nil {
+			set = make(map[string]int64)
+			f.zsets[key] = set
+		}
+		for m, score := range set {
+			if score <= now-window {
+				delete(set, m)
+			}
+		}
+		if int64(len(set)) < limit {
+			set[member] = now
+			return []int64{1, 0}, nil
+		}
+		oldest := now
+		for _, score := range set {
+			if score < oldest {
+				oldest = score
+			}
+		}
+		retryAfter := window - (now - oldest)
+		if retryAfter < 0 {
+			retryAfter = 0
+		}
+		return []int64{0, retryAfter}, nil
+
+	default:
+		return nil, fmt.Errorf("fakeScriptBackend: unrecognized script")
+	}
+}
+
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case int:
+		return int64(n)
+	default:
+		panic(fmt.Sprintf("toInt64: unsupported type %T", v))
+	}
+}
+
+func withFakeCache(t *testing.T, backend CacheBackend) {
+	t.Helper()
+	old := cache
+	cache = backend
+	t.Cleanup(func() { cache = old })
+}
+
+func TestCacheLockAcquireRelease(t *testing.T) {
+	withFakeCache(t, newFakeScriptBackend())
+
+	unlock, err := CacheLock("lock:test", time.Minute)
+	if err != nil {
+		t.Fatalf("CacheLock: unexpected error: %v", err)
+	}
+
+	if _, err := CacheLock("lock:test", time.Minute); err == nil {
+		t.Error("CacheLock: expected error acquiring an already-held lock, got nil")
+	}
+
+	unlock()
+
+	unlock2, err := CacheLock("lock:test", time.Minute)
+	if err != nil {
+		t.Fatalf("CacheLock: unexpected error re-acquiring after Unlock: %v", err)
+	}
+	unlock2()
+}
+
+func TestCacheLockFencingPreventsStaleUnlock(t *testing.T) {
+	fake := newFakeScriptBackend()
+	withFakeCache(t, fake)
+
+	unlock, err := CacheLock("lock:fence", time.Minute)
+	if err != nil {
+		t.Fatalf("CacheLock: unexpected error: %v", err)
+	}
+
+	// Simulate this holder's lease having expired and a different worker
+	// acquiring the lock (a new fencing token) before Unlock runs.
+	fake.mu.Lock()
+	fake.kv["lock:fence"] = "other-workers-token"
+	fake.mu.Unlock()
+
+	unlock()
+
+	fake.mu.Lock()
+	got := fake.kv["lock:fence"]
+	fake.mu.Unlock()
+	if got != "other-workers-token" {
+		t.Errorf("stale Unlock touched another worker's lock: kv[key] = %q, want unchanged %q", got, "other-workers-token")
+	}
+}
+
+func TestCacheLockRequiresScriptBackend(t *testing.T) {
+	withFakeCache(t, newInMemoryCacheBackend())
+
+	if _, err := CacheLock("lock:test", time.Minute); err == nil {
+		t.Error("CacheLock: expected error against a backend without script support, got nil")
+	}
+}
+
+func TestCacheRateLimit(t *testing.T) {
+	withFakeCache(t, newFakeScriptBackend())
+
+	const limit = 2
+	window := time.Minute
+
+	for i := 0; i < limit; i++ {
+		allowed, retryAfter := CacheRateLimit("rl:test", limit, window)
+		if !allowed {
+			t.Fatalf("request %d: expected allowed, got denied (retryAfter=%v)", i, retryAfter)
+		}
+	}
+
+	allowed, retryAfter := CacheRateLimit("rl:test", limit, window)
+	if allowed {
+		t.Fatal("request over limit: expected denied, got allowed")
+	}
+	if retryAfter <= 0 {
+		t.Errorf("request over limit: retryAfter = %v, want > 0", retryAfter)
+	}
+}
+
+func TestCacheRateLimitFailsOpenWithoutScriptBackend(t *testing.T) {
+	withFakeCache(t, newInMemoryCacheBackend())
+
+	allowed, retryAfter := CacheRateLimit("rl:test", 1, time.Minute)
+	if !allowed {
+		t.Error("CacheRateLimit: expected fail-open (allowed) against a backend without script support")
+	}
+	if retryAfter != 0 {
+		t.Errorf("CacheRateLimit: retryAfter = %v, want 0", retryAfter)
+	}
+}
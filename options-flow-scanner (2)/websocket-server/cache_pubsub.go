@@ -0,0 +1,263 @@
+// ================================================
+// CACHE PUB/SUB FAN-OUT
+// ================================================
+// cache_swr.go already has its own pub/sub channel for dashboard-cache
+// invalidation (InvalidateSymbol / startCacheInvalidationSubscriber) - this
+// file is the hot-path cache's equivalent: CachePublish/CacheSubscribe let
+// the trading helpers in cache.go push real-time deltas (e.g. CacheGEX
+// publishing to "updates:gex:SPY") so the WebSocket layer can fan them out
+// without polling, and startCacheExpiryInvalidator turns a TTL expiry on one
+// node into an L1 (cache_l1.go) eviction on every node.
+// ================================================
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	cacheExpiredKeyEventChannel = "__keyevent@0__:expired"
+
+	cachePubSubBaseDelay = 1 * time.Second
+	cachePubSubMaxDelay  = 30 * time.Second
+)
+
+// CachePubSubBackend is implemented by CacheBackends capable of real network
+// pub/sub - both Redis-backed variants in cache_backend.go. The in-memory
+// backend doesn't implement it, so CachePublish/CacheSubscribe simply error
+// against it - the same optional-interface pattern transport.go uses for
+// Pinger.
+type CachePubSubBackend interface {
+	Publish(ctx context.Context, channel string, payload []byte) error
+	Subscribe(ctx context.Context, channels ...string) cacheSubscription
+}
+
+// cacheSubscription is the minimal handle the subscribe loop needs -
+// satisfied directly by *redis.PubSub.
+type cacheSubscription interface {
+	Channel(...redis.ChannelOption) <-chan *redis.Message
+	Close() error
+}
+
+func (b *redisCacheBackend) Publish(ctx context.Context, channel string, payload []byte) error {
+	return b.client.Publish(ctx, channel, payload).Err()
+}
+
+func (b *redisCacheBackend) Subscribe(ctx context.Context, channels ...string) cacheSubscription {
+	return b.client.Subscribe(ctx, channels...)
+}
+
+// CachePublish publishes payload on channel through the active cache
+// backend, if it supports pub/sub.
+func CachePublish(channel string, payload []byte) error {
+	pubsub, ok := cache.(CachePubSubBackend)
+	if !ok {
+		return fmt.Errorf("cache backend does not support pub/sub")
+	}
+
+	ctx, cancel := context.WithTimeout(cacheCtx, 2*time.Second)
+	defer cancel()
+	return pubsub.Publish(ctx, channel, payload)
+}
+
+// CacheSubscribe subscribes to channels on the active backend and delivers
+// each payload, decoded as a Message, on the returned channel. The
+// subscription reconnects with full-jitter exponential backoff on any
+// connection error (the same formula PolygonClient.scheduleReconnect uses in
+// polygon-client.go) and resumes the same channel list, so a transient
+// DragonflyDB blip doesn't silently end the caller's stream. The returned
+// channel closes once ctx is canceled.
+func CacheSubscribe(ctx context.Context, channels ...string) (<-chan Message, error) {
+	raw, err := subscribeRaw(ctx, channels...)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan Message, 64)
+	go func() {
+		defer close(out)
+		for payload := range raw {
+			var msg Message
+			if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+				log.Printf("⚠️  Cache pub/sub: failed to decode message: %v", err)
+				continue
+			}
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// subscribeRaw is CacheSubscribe's reconnecting loop, one level below
+// Message decoding - used directly by startCacheExpiryInvalidator, whose
+// payload is a bare key name rather than a JSON Message.
+func subscribeRaw(ctx context.Context, channels ...string) (<-chan string, error) {
+	pubsub, ok := cache.(CachePubSubBackend)
+	if !ok {
+		return nil, fmt.Errorf("cache backend does not support pub/sub")
+	}
+
+	out := make(chan string, 64)
+	go runCacheSubscription(ctx, pubsub, channels, out)
+	return out, nil
+}
+
+func runCacheSubscription(ctx context.Context, backend CachePubSubBackend, channels []string, out chan<- string) {
+	defer close(out)
+
+	attempt := 0
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		sub := backend.Subscribe(ctx, channels...)
+		attempt = 0 // connecting succeeded; backoff resets once messages flow
+
+		for raw := range sub.Channel(redis.WithChannelSize(64)) {
+			select {
+			case out <- raw.Payload:
+			case <-ctx.Done():
+				sub.Close()
+				return
+			}
+		}
+		sub.Close()
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		attempt++
+		delay := cachePubSubBackoff(attempt)
+		log.Printf("🔄 Cache pub/sub: reconnecting to %v in %v (attempt %d)", channels, delay, attempt)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// cachePubSubBackoff computes a full-jitter exponential backoff delay:
+// rand(0, min(cap, base*2^attempt)) - the same formula
+// PolygonClient.scheduleReconnect uses in polygon-client.go.
+func cachePubSubBackoff(attempt int) time.Duration {
+	capped := cachePubSubBaseDelay * time.Duration(uint64(1)<<uint(attempt-1))
+	if capped <= 0 || capped > cachePubSubMaxDelay {
+		capped = cachePubSubMaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(capped) + 1))
+}
+
+// ================================================
+// PER-CHANNEL HANDLER REGISTRATION
+// ================================================
+
+var (
+	cacheHandlersMu sync.RWMutex
+	cacheHandlers   = map[string][]func(Message){}
+)
+
+// RegisterCacheHandler registers handler to be invoked for every Message
+// published on channel, once startCachePubSubDispatcher is running.
+func RegisterCacheHandler(channel string, handler func(Message)) {
+	cacheHandlersMu.Lock()
+	defer cacheHandlersMu.Unlock()
+	cacheHandlers[channel] = append(cacheHandlers[channel], handler)
+}
+
+// startCachePubSubDispatcher subscribes to every channel with a registered
+// handler and dispatches each Message to it for the lifetime of ctx. It's a
+// no-op if nothing has called RegisterCacheHandler yet.
+func startCachePubSubDispatcher(ctx context.Context) error {
+	cacheHandlersMu.RLock()
+	channels := make([]string, 0, len(cacheHandlers))
+	for channel := range cacheHandlers {
+		channels = append(channels, channel)
+	}
+	cacheHandlersMu.RUnlock()
+
+	if len(channels) == 0 {
+		return nil
+	}
+
+	msgs, err := CacheSubscribe(ctx, channels...)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for msg := range msgs {
+			cacheHandlersMu.RLock()
+			handlers := cacheHandlers[msg.Channel]
+			cacheHandlersMu.RUnlock()
+			for _, handler := range handlers {
+				handler(msg)
+			}
+		}
+	}()
+	return nil
+}
+
+// ================================================
+// TTL-EXPIRY -> L1 INVALIDATION
+// ================================================
+
+// startCacheExpiryInvalidator subscribes to the backend's key-expiry
+// keyspace notifications and evicts the matching key from the L1 tier
+// (cache_l1.go), so a TTL expiring on one node doesn't leave every other
+// node's L1 serving a stale entry until its own copy separately expires.
+// Requires `notify-keyspace-events Ex` (or better) configured on the backend
+// and assumes db 0; a backend without that config, or a non-pub/sub backend
+// like the in-memory one, just makes this a no-op - L1 entries still expire
+// on their own TTL regardless.
+func startCacheExpiryInvalidator(ctx context.Context) {
+	raw, err := subscribeRaw(ctx, cacheExpiredKeyEventChannel)
+	if err != nil {
+		return
+	}
+
+	log.Println("📡 Cache expiry invalidator started")
+	for key := range raw {
+		l1.del(key)
+	}
+}
+
+// publishCacheUpdate wraps data in a Message and publishes it on channel,
+// logging (rather than returning) any failure - a cache write that already
+// succeeded shouldn't fail just because nobody's listening for the
+// real-time delta.
+func publishCacheUpdate(channel string, data interface{}) {
+	if _, ok := cache.(CachePubSubBackend); !ok {
+		return
+	}
+
+	payload, err := json.Marshal(Message{
+		Type:      "cache-update",
+		Channel:   channel,
+		Data:      data,
+		Timestamp: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		log.Printf("⚠️  Cache update publish: failed to marshal %s: %v", channel, err)
+		return
+	}
+
+	if err := CachePublish(channel, payload); err != nil {
+		log.Printf("⚠️  Cache update publish failed for %s: %v", channel, err)
+	}
+}
@@ -0,0 +1,277 @@
+// ================================================
+// VERSIONED CACHE VALUE SERIALIZATION
+// ================================================
+// CacheSet always JSON-marshals, which is the most expensive encoding we
+// could pick for hot-path Greeks/GEX blobs. CacheSetTyped/CacheGetTyped add
+// a pluggable encoding (JSON, MessagePack, or protobuf) plus optional
+// compression above a size threshold, with a 1-byte envelope header
+// (codec + compression + schema version) prefixed onto the payload so
+// CacheGetTyped can tell a new envelope-framed value from a value CacheSet
+// wrote before this rollout - the header's top bit is never set by any
+// legal JSON-starting byte, so a headerless payload is unambiguously
+// legacy JSON.
+//
+// Named CacheValueCodec rather than Codec to avoid colliding with the
+// websocket wire Codec in codec.go - same package, different concern (cache
+// storage encoding vs. client wire format).
+// ================================================
+
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/pierrec/lz4/v4"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// cacheCodecID identifies which serializer encoded a cache value's payload.
+type cacheCodecID byte
+
+const (
+	cacheCodecJSON cacheCodecID = iota
+	cacheCodecMsgpack
+	cacheCodecProtobuf
+)
+
+// cacheCompressionID identifies which compressor, if any, was applied after
+// encoding.
+type cacheCompressionID byte
+
+const (
+	cacheCompressionNone cacheCompressionID = iota
+	cacheCompressionSnappy
+	cacheCompressionLZ4
+)
+
+// cacheSchemaVersion is bumped whenever the envelope layout below changes
+// incompatibly. decodeCacheValue only treats a payload as legacy JSON when
+// it has no envelope header at all; a header present with a version it
+// doesn't recognize is a clear error instead, since those bytes are never
+// valid JSON on their own.
+const cacheSchemaVersion = 1
+
+// cacheCompressThreshold is the minimum encoded size worth compressing -
+// most Greeks/GEX blobs are small enough that compression overhead would
+// outweigh the savings.
+const cacheCompressThreshold = 512
+
+// cacheHeaderMagic marks byte 0 of an envelope-framed value. Every legal
+// JSON-starting byte (`{`, `[`, `"`, a digit, `-`, `t`, `f`, `n`) is ASCII
+// and has this bit clear, so its presence unambiguously distinguishes an
+// envelope-framed value from bare legacy JSON.
+const cacheHeaderMagic = 0x80
+
+// cacheDefaultCompression is applied by CacheSetTyped whenever the encoded
+// payload clears cacheCompressThreshold.
+var cacheDefaultCompression = parseCacheCompression(getEnv("CACHE_COMPRESSION", "snappy"))
+
+func parseCacheCompression(raw string) cacheCompressionID {
+	switch strings.ToLower(raw) {
+	case "lz4":
+		return cacheCompressionLZ4
+	case "none", "off":
+		return cacheCompressionNone
+	default:
+		return cacheCompressionSnappy
+	}
+}
+
+// encodeCacheValueHeader packs codec (2 bits), compression (2 bits), and the
+// schema version (3 bits) into one byte, with cacheHeaderMagic set in the
+// top bit.
+func encodeCacheValueHeader(codec cacheCodecID, compression cacheCompressionID) byte {
+	return cacheHeaderMagic | byte(cacheSchemaVersion&0x7)<<4 | byte(compression&0x3)<<2 | byte(codec&0x3)
+}
+
+// parseCacheValueHeader reverses encodeCacheValueHeader. recognized is false
+// when cacheHeaderMagic isn't set, meaning b is the first byte of a legacy
+// JSON payload, not an envelope header.
+func parseCacheValueHeader(b byte) (codec cacheCodecID, compression cacheCompressionID, version byte, recognized bool) {
+	if b&cacheHeaderMagic == 0 {
+		return 0, 0, 0, false
+	}
+	version = (b >> 4) & 0x7
+	compression = cacheCompressionID((b >> 2) & 0x3)
+	codec = cacheCodecID(b & 0x3)
+	return codec, compression, version, true
+}
+
+// CacheSetTyped encodes value with codec, compressing the result with
+// cacheDefaultCompression once it clears cacheCompressThreshold, and stores
+// it through the same L1+backend write-through path as CacheSet.
+func CacheSetTyped[T any](key string, value T, ttl time.Duration, codec cacheCodecID) error {
+	payload, err := encodeCacheValue(value, codec)
+	if err != nil {
+		return err
+	}
+	return cacheSetBytes(key, payload, ttl)
+}
+
+// CacheGetTyped retrieves and decodes a value written by CacheSetTyped (or
+// CacheSet - decodeCacheValue treats a headerless payload as legacy JSON).
+func CacheGetTyped[T any](key string) (T, error) {
+	var zero T
+	raw, err := CacheGet(key)
+	if err != nil {
+		return zero, err
+	}
+	return decodeCacheValue[T](raw)
+}
+
+func encodeCacheValue[T any](value T, codec cacheCodecID) ([]byte, error) {
+	encoded, err := marshalCacheValue(value, codec)
+	if err != nil {
+		return nil, err
+	}
+
+	compression := cacheCompressionNone
+	if len(encoded) >= cacheCompressThreshold {
+		compression = cacheDefaultCompression
+		encoded, err = compressCacheValue(encoded, compression)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	out := make([]byte, 0, len(encoded)+1)
+	out = append(out, encodeCacheValueHeader(codec, compression))
+	return append(out, encoded...), nil
+}
+
+func decodeCacheValue[T any](raw []byte) (T, error) {
+	var zero T
+	if len(raw) == 0 {
+		return zero, fmt.Errorf("cache: empty value")
+	}
+
+	codec, compression, version, recognized := parseCacheValueHeader(raw[0])
+	body := raw[1:]
+	if !recognized {
+		// No envelope header at all (raw[0]'s top bit is clear) - this is a
+		// legacy value CacheSet wrote before this rollout, bare JSON with no
+		// header byte to strip.
+		codec, compression, body = cacheCodecJSON, cacheCompressionNone, raw
+	} else if version != cacheSchemaVersion {
+		// Envelope header present but from a schema version we don't know how
+		// to decode. body still has the header byte stripped, so it's neither
+		// valid legacy JSON (that path never has a header) nor a version we
+		// understand - fail clearly instead of misreading it as one.
+		return zero, fmt.Errorf("cache: unsupported schema version %d", version)
+	}
+
+	decoded, err := decompressCacheValue(body, compression)
+	if err != nil {
+		return zero, err
+	}
+
+	var out T
+	if err := unmarshalCacheValue(decoded, codec, &out); err != nil {
+		return zero, err
+	}
+	return out, nil
+}
+
+func marshalCacheValue(value interface{}, codec cacheCodecID) ([]byte, error) {
+	switch codec {
+	case cacheCodecJSON:
+		return json.Marshal(value)
+	case cacheCodecMsgpack:
+		return msgpack.Marshal(value)
+	case cacheCodecProtobuf:
+		marshaler, ok := value.(interface{ Marshal() ([]byte, error) })
+		if !ok {
+			return nil, fmt.Errorf("cache: %T does not implement Marshal() for the protobuf codec", value)
+		}
+		return marshaler.Marshal()
+	default:
+		return nil, fmt.Errorf("cache: unknown codec %d", codec)
+	}
+}
+
+func unmarshalCacheValue(data []byte, codec cacheCodecID, out interface{}) error {
+	switch codec {
+	case cacheCodecJSON:
+		return json.Unmarshal(data, out)
+	case cacheCodecMsgpack:
+		return msgpack.Unmarshal(data, out)
+	case cacheCodecProtobuf:
+		unmarshaler, ok := out.(interface{ Unmarshal([]byte) error })
+		if !ok {
+			return fmt.Errorf("cache: %T does not implement Unmarshal() for the protobuf codec", out)
+		}
+		return unmarshaler.Unmarshal(data)
+	default:
+		return fmt.Errorf("cache: unknown codec %d", codec)
+	}
+}
+
+func compressCacheValue(data []byte, compression cacheCompressionID) ([]byte, error) {
+	switch compression {
+	case cacheCompressionSnappy:
+		return s2.EncodeSnappy(nil, data), nil
+	case cacheCompressionLZ4:
+		return lz4CompressValue(data)
+	default:
+		return data, nil
+	}
+}
+
+func decompressCacheValue(data []byte, compression cacheCompressionID) ([]byte, error) {
+	switch compression {
+	case cacheCompressionNone:
+		return data, nil
+	case cacheCompressionSnappy:
+		return s2.Decode(nil, data)
+	case cacheCompressionLZ4:
+		return lz4DecompressValue(data)
+	default:
+		return nil, fmt.Errorf("cache: unknown compression %d", compression)
+	}
+}
+
+// lz4CompressValue prefixes the compressed block with src's original length
+// so lz4DecompressValue knows how large a destination buffer to allocate -
+// LZ4's block API has no end-of-stream marker of its own. If the block
+// compressor can't shrink the input, the original bytes are stored verbatim
+// instead (the remaining payload's length then equals the length prefix).
+func lz4CompressValue(src []byte) ([]byte, error) {
+	dst := make([]byte, lz4.CompressBlockBound(len(src)))
+	var c lz4.Compressor
+	n, err := c.CompressBlock(src, dst)
+	if err != nil {
+		return nil, fmt.Errorf("lz4 compress: %w", err)
+	}
+
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, uint32(len(src)))
+
+	if n == 0 || n >= len(src) {
+		return append(header, src...), nil
+	}
+	return append(header, dst[:n]...), nil
+}
+
+func lz4DecompressValue(data []byte) ([]byte, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("lz4 decompress: truncated header")
+	}
+
+	originalLen := int(binary.BigEndian.Uint32(data[:4]))
+	body := data[4:]
+	if len(body) == originalLen {
+		return body, nil // stored verbatim - lz4CompressValue found it incompressible
+	}
+
+	dst := make([]byte, originalLen)
+	n, err := lz4.UncompressBlock(body, dst)
+	if err != nil {
+		return nil, fmt.Errorf("lz4 decompress: %w", err)
+	}
+	return dst[:n], nil
+}
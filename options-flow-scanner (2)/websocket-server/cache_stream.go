@@ -0,0 +1,235 @@
+// ================================================
+// REDIS STREAMS (OPTIONS FLOW)
+// ================================================
+// CacheFlow used to be a trimmed LPUSH list (CacheLPush/CacheLRange below),
+// which has no stable per-entry ID - a reconnecting client has no way to ask
+// "what did I miss" other than "give me the last N", and there's no way to
+// load-balance delivery across more than one downstream analyzer. Redis
+// Streams fixes both: every XADD gets a monotonic ID, XRANGE/XREAD can
+// resume from a given ID, and a consumer group can fan the same stream out
+// to multiple workers with XACK-based at-least-once delivery.
+//
+// CacheLPush/CacheLRange stay in the codebase as a thin, un-migrated shim -
+// CacheFlow itself now writes to a stream instead.
+// ================================================
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// flowStreamMaxLen bounds each flow stream to approximately this many
+// entries, trimmed both inline (XADD ... MAXLEN ~ N) and by the background
+// trimmer below.
+const flowStreamMaxLen = 1000
+
+// CacheStreamBackend is implemented by CacheBackends capable of Redis
+// Streams - both Redis-backed variants in cache_backend.go. The in-memory
+// backend doesn't implement it; CacheStream* functions error against it, the
+// same optional-interface pattern cache_pubsub.go uses for pub/sub.
+type CacheStreamBackend interface {
+	XAdd(ctx context.Context, stream string, fields map[string]interface{}, maxLen int64) (string, error)
+	XRange(ctx context.Context, stream, start string, count int64) ([]redis.XMessage, error)
+	XGroupCreateMkStream(ctx context.Context, stream, group string) error
+	XReadGroup(ctx context.Context, stream, group, consumer string, count int64) ([]redis.XMessage, error)
+	XAck(ctx context.Context, stream, group string, ids ...string) error
+	XTrimApprox(ctx context.Context, stream string, maxLen int64) error
+}
+
+func (b *redisCacheBackend) XAdd(ctx context.Context, stream string, fields map[string]interface{}, maxLen int64) (string, error) {
+	return b.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		MaxLen: maxLen,
+		Approx: true,
+		Values: fields,
+	}).Result()
+}
+
+func (b *redisCacheBackend) XRange(ctx context.Context, stream, start string, count int64) ([]redis.XMessage, error) {
+	return b.client.XRangeN(ctx, stream, start, "+", count).Result()
+}
+
+func (b *redisCacheBackend) XGroupCreateMkStream(ctx context.Context, stream, group string) error {
+	return b.client.XGroupCreateMkStream(ctx, stream, group, "$").Err()
+}
+
+func (b *redisCacheBackend) XReadGroup(ctx context.Context, stream, group, consumer string, count int64) ([]redis.XMessage, error) {
+	streams, err := b.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{stream, ">"},
+		Count:    count,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(streams) == 0 {
+		return nil, nil
+	}
+	return streams[0].Messages, nil
+}
+
+func (b *redisCacheBackend) XAck(ctx context.Context, stream, group string, ids ...string) error {
+	return b.client.XAck(ctx, stream, group, ids...).Err()
+}
+
+func (b *redisCacheBackend) XTrimApprox(ctx context.Context, stream string, maxLen int64) error {
+	return b.client.XTrimMaxLenApprox(ctx, stream, maxLen, 0).Err()
+}
+
+// isGroupExistsErr reports whether err is Redis' BUSYGROUP error, returned
+// when the consumer group already exists - not a real failure here, since
+// CacheStreamGroupRead always tries to create the group on every call.
+func isGroupExistsErr(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "BUSYGROUP")
+}
+
+// CacheStreamAdd appends fields to stream as a new Streams entry, trimming
+// the stream to approximately maxLen entries, and returns the entry's
+// server-assigned ID.
+func CacheStreamAdd(stream string, fields map[string]interface{}, maxLen int64) (string, error) {
+	backend, ok := cache.(CacheStreamBackend)
+	if !ok {
+		return "", fmt.Errorf("cache backend does not support streams")
+	}
+
+	ctx, cancel := context.WithTimeout(cacheCtx, 2*time.Second)
+	defer cancel()
+
+	id, err := backend.XAdd(ctx, stream, fields, maxLen)
+	if err != nil {
+		return "", err
+	}
+
+	trackFlowStream(stream)
+	return id, nil
+}
+
+// CacheStreamRead returns up to count entries from stream after lastID
+// ("0" or "" reads from the beginning) - useful for a reconnecting client
+// resuming from the last ID it saw.
+func CacheStreamRead(stream, lastID string, count int64) ([]redis.XMessage, error) {
+	backend, ok := cache.(CacheStreamBackend)
+	if !ok {
+		return nil, fmt.Errorf("cache backend does not support streams")
+	}
+
+	ctx, cancel := context.WithTimeout(cacheCtx, 2*time.Second)
+	defer cancel()
+
+	start := "-"
+	if lastID != "" && lastID != "0" {
+		start = "(" + lastID
+	}
+	return backend.XRange(ctx, stream, start, count)
+}
+
+// CacheStreamGroupRead reads up to count new entries from stream for
+// group/consumer, creating the group on first use (starting from "$", so a
+// brand-new group isn't replayed every already-existing entry). Entries must
+// be acknowledged with CacheStreamAck once processed, or a consumer crash
+// leaves them pending for another consumer in the group to claim.
+func CacheStreamGroupRead(stream, group, consumer string, count int64) ([]redis.XMessage, error) {
+	backend, ok := cache.(CacheStreamBackend)
+	if !ok {
+		return nil, fmt.Errorf("cache backend does not support streams")
+	}
+
+	ctx, cancel := context.WithTimeout(cacheCtx, 2*time.Second)
+	defer cancel()
+
+	if err := backend.XGroupCreateMkStream(ctx, stream, group); err != nil && !isGroupExistsErr(err) {
+		return nil, fmt.Errorf("failed to create consumer group %s on %s: %w", group, stream, err)
+	}
+
+	return backend.XReadGroup(ctx, stream, group, consumer, count)
+}
+
+// CacheStreamAck acknowledges ids on stream for group, removing them from
+// the group's pending-entries list.
+func CacheStreamAck(stream, group string, ids ...string) error {
+	backend, ok := cache.(CacheStreamBackend)
+	if !ok {
+		return fmt.Errorf("cache backend does not support streams")
+	}
+
+	ctx, cancel := context.WithTimeout(cacheCtx, 2*time.Second)
+	defer cancel()
+
+	return backend.XAck(ctx, stream, group, ids...)
+}
+
+// flowStreamFields wraps flowData as a single JSON "payload" field, since
+// flowData's shape is caller-defined (interface{}, like CacheFlow's
+// parameter) and Streams fields are a flat key/value map rather than
+// arbitrary nested structures.
+func flowStreamFields(flowData interface{}) (map[string]interface{}, error) {
+	payload, err := json.Marshal(flowData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal flow data: %w", err)
+	}
+	return map[string]interface{}{"payload": payload}, nil
+}
+
+// ================================================
+// BACKGROUND TRIMMER
+// ================================================
+// XADD's inline MAXLEN ~ N keeps trimming roughly O(1) per add, but still
+// runs on every single write. flowStreams tracks every stream CacheStreamAdd
+// has touched so startFlowStreamTrimmer can instead batch an approximate
+// XTRIM over all of them on a slow interval - cheaper in aggregate than
+// paying a trim check on every write.
+var (
+	flowStreamsMu sync.Mutex
+	flowStreams   = make(map[string]bool)
+)
+
+func trackFlowStream(stream string) {
+	flowStreamsMu.Lock()
+	flowStreams[stream] = true
+	flowStreamsMu.Unlock()
+}
+
+// startFlowStreamTrimmer periodically XTRIMs every stream CacheStreamAdd has
+// written to down to approximately flowStreamMaxLen entries.
+func startFlowStreamTrimmer(ctx context.Context) {
+	backend, ok := cache.(CacheStreamBackend)
+	if !ok {
+		return
+	}
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			flowStreamsMu.Lock()
+			streams := make([]string, 0, len(flowStreams))
+			for stream := range flowStreams {
+				streams = append(streams, stream)
+			}
+			flowStreamsMu.Unlock()
+
+			trimCtx, cancel := context.WithTimeout(cacheCtx, 10*time.Second)
+			for _, stream := range streams {
+				if err := backend.XTrimApprox(trimCtx, stream, flowStreamMaxLen); err != nil {
+					log.Printf("⚠️  Flow stream trimmer: failed to trim %s: %v", stream, err)
+				}
+			}
+			cancel()
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,307 @@
+// ================================================
+// STALE-WHILE-REVALIDATE DASHBOARD CACHE
+// ================================================
+// Replaces the getFromCache/setCache pass-through with a typed Cache[T]:
+// singleflight coalesces concurrent misses for the same key down to one
+// generator call, and a stale hit is served immediately while a background
+// goroutine refreshes it. Invalidation is symbol-scoped and fans out to
+// every server instance over a Redis pub/sub channel.
+// ================================================
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const cacheInvalidateChannel = "cache:invalidate"
+
+// cacheEntry is the on-wire shape stored in Redis for every SWR-backed key.
+type cacheEntry struct {
+	Payload    json.RawMessage `json:"payload"`
+	FreshUntil int64           `json:"freshUntil"` // unix millis; served without a background refresh until this passes
+	StaleUntil int64           `json:"staleUntil"` // unix millis; served at all until this passes
+}
+
+// Cache is a typed, Redis-backed, stale-while-revalidate cache. Concurrent
+// Get calls for the same key that miss share a single in-flight generator
+// call via singleflight.
+type Cache[T any] struct {
+	fresh time.Duration
+	stale time.Duration
+	group singleflight.Group
+}
+
+// NewCache returns a Cache whose entries are served fresh (no refresh
+// triggered) for `fresh`, then served stale-but-valid (triggering a
+// background refresh on read) for up to `stale` longer before expiring.
+func NewCache[T any](fresh, stale time.Duration) *Cache[T] {
+	return &Cache[T]{fresh: fresh, stale: stale}
+}
+
+// Get returns the value cached under key, calling gen to (re)populate it on
+// a miss or once it has expired past the stale window. A stale-but-valid hit
+// is returned immediately while gen reruns in the background.
+func (c *Cache[T]) Get(key string, gen func() (T, error)) (T, error) {
+	var zero T
+
+	if entry, ok := cacheRedisGet(key); ok {
+		var value T
+		if err := json.Unmarshal(entry.Payload, &value); err == nil {
+			now := time.Now().UnixMilli()
+			recordCacheHit(key)
+			if now > entry.FreshUntil {
+				go c.refresh(key, gen)
+			}
+			return value, nil
+		}
+	}
+
+	recordCacheMiss(key)
+
+	v, err, _ := c.group.Do(key, func() (interface{}, error) {
+		value, genErr := gen()
+		if genErr != nil {
+			return nil, genErr
+		}
+		c.store(key, value)
+		return value, nil
+	})
+	if err != nil {
+		return zero, err
+	}
+	return v.(T), nil
+}
+
+// refresh regenerates key in the background and records the latency, Get's
+// stale case runs this as its own goroutine so the caller isn't blocked.
+func (c *Cache[T]) refresh(key string, gen func() (T, error)) {
+	start := time.Now()
+	_, err, _ := c.group.Do(key, func() (interface{}, error) {
+		value, genErr := gen()
+		if genErr != nil {
+			return nil, genErr
+		}
+		c.store(key, value)
+		return value, nil
+	})
+	if err != nil {
+		log.Printf("⚠️  Background cache refresh failed for %s: %v", key, err)
+		return
+	}
+	recordCacheRefresh(key, time.Since(start))
+}
+
+// store writes value into Redis as a cacheEntry, fresh for c.fresh and then
+// stale-but-servable for c.stale beyond that.
+func (c *Cache[T]) store(key string, value T) {
+	payload, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("❌ Failed to marshal cache value for %s: %v", key, err)
+		return
+	}
+
+	now := time.Now()
+	entry := cacheEntry{
+		Payload:    payload,
+		FreshUntil: now.Add(c.fresh).UnixMilli(),
+		StaleUntil: now.Add(c.fresh + c.stale).UnixMilli(),
+	}
+
+	if err := cacheRedisSet(key, entry, c.fresh+c.stale); err != nil {
+		log.Printf("❌ Failed to set cache for %s: %v", key, err)
+	}
+}
+
+// ================================================
+// REDIS-BACKED ENTRY STORAGE
+// ================================================
+// Uses the package-wide redisClient (shared with pub/sub broadcast), not
+// dragonflyClient - the dashboard cache and the hot-path trade cache are
+// different tiers with different eviction needs.
+
+func cacheRedisGet(key string) (cacheEntry, bool) {
+	if redisClient == nil {
+		return cacheEntry{}, false
+	}
+
+	val, err := redisClient.Get(ctx, key).Bytes()
+	if err != nil {
+		return cacheEntry{}, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(val, &entry); err != nil {
+		return cacheEntry{}, false
+	}
+
+	now := time.Now().UnixMilli()
+	if now > entry.StaleUntil {
+		return cacheEntry{}, false
+	}
+
+	return entry, true
+}
+
+func cacheRedisSet(key string, entry cacheEntry, ttl time.Duration) error {
+	if redisClient == nil {
+		return fmt.Errorf("redis not available")
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return redisClient.Set(ctx, key, data, ttl).Err()
+}
+
+// ================================================
+// INVALIDATION
+// ================================================
+
+// cacheInvalidation is published on cacheInvalidateChannel whenever Polygon
+// pushes new data for a symbol that affects cached dashboard endpoints.
+type cacheInvalidation struct {
+	Symbol string   `json:"symbol"`
+	Keys   []string `json:"keys"`
+}
+
+// InvalidateSymbol publishes a symbol-scoped invalidation so every server
+// instance wipes the dashboard keys derived from that symbol's data.
+func InvalidateSymbol(symbol string, keys ...string) {
+	if redisClient == nil {
+		return
+	}
+
+	data, err := json.Marshal(cacheInvalidation{Symbol: symbol, Keys: keys})
+	if err != nil {
+		log.Printf("❌ Failed to marshal cache invalidation: %v", err)
+		return
+	}
+
+	if err := redisClient.Publish(ctx, cacheInvalidateChannel, data).Err(); err != nil {
+		log.Printf("❌ Failed to publish cache invalidation: %v", err)
+	}
+}
+
+// startCacheInvalidationSubscriber listens for invalidations published by any
+// server instance (including this one) and deletes the affected keys so the
+// next Get regenerates them.
+func startCacheInvalidationSubscriber() {
+	if redisClient == nil {
+		return
+	}
+
+	pubsub := redisClient.Subscribe(ctx, cacheInvalidateChannel)
+	defer pubsub.Close()
+
+	log.Println("📡 Cache invalidation subscriber started")
+
+	for {
+		msg, err := pubsub.ReceiveMessage(ctx)
+		if err != nil {
+			log.Printf("❌ Cache invalidation subscriber error: %v", err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		var inv cacheInvalidation
+		if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+			log.Printf("❌ Failed to parse cache invalidation: %v", err)
+			continue
+		}
+
+		for _, key := range inv.Keys {
+			if err := redisClient.Del(ctx, key).Err(); err != nil {
+				log.Printf("⚠️  Failed to invalidate cache key %s: %v", key, err)
+			}
+		}
+	}
+}
+
+// ================================================
+// PER-KEY METRICS
+// ================================================
+
+type cacheKeyMetrics struct {
+	Hits             int64 `json:"hits"`
+	Misses           int64 `json:"misses"`
+	Refreshes        int64 `json:"refreshes"`
+	RefreshNanosSum  int64 `json:"-"`
+}
+
+var (
+	cacheMetricsMu sync.Mutex
+	cacheMetrics   = make(map[string]*cacheKeyMetrics)
+)
+
+// cacheMetricsForLocked returns (creating if absent) the metrics entry for
+// key. Caller must hold cacheMetricsMu.
+func cacheMetricsForLocked(key string) *cacheKeyMetrics {
+	m, ok := cacheMetrics[key]
+	if !ok {
+		m = &cacheKeyMetrics{}
+		cacheMetrics[key] = m
+	}
+	return m
+}
+
+func recordCacheHit(key string) {
+	cacheMetricsMu.Lock()
+	defer cacheMetricsMu.Unlock()
+	cacheMetricsForLocked(key).Hits++
+}
+
+func recordCacheMiss(key string) {
+	cacheMetricsMu.Lock()
+	defer cacheMetricsMu.Unlock()
+	cacheMetricsForLocked(key).Misses++
+}
+
+func recordCacheRefresh(key string, latency time.Duration) {
+	cacheMetricsMu.Lock()
+	defer cacheMetricsMu.Unlock()
+	m := cacheMetricsForLocked(key)
+	m.Refreshes++
+	m.RefreshNanosSum += latency.Nanoseconds()
+}
+
+// cacheMetricsSnapshot renders the current per-key metrics for /metrics,
+// including the average refresh latency in milliseconds.
+func cacheMetricsSnapshot() map[string]interface{} {
+	cacheMetricsMu.Lock()
+	defer cacheMetricsMu.Unlock()
+
+	snapshot := make(map[string]interface{}, len(cacheMetrics))
+	for key, m := range cacheMetrics {
+		avgRefreshMs := 0.0
+		if m.Refreshes > 0 {
+			avgRefreshMs = float64(m.RefreshNanosSum) / float64(m.Refreshes) / float64(time.Millisecond)
+		}
+		snapshot[key] = map[string]interface{}{
+			"hits":             m.Hits,
+			"misses":           m.Misses,
+			"refreshes":        m.Refreshes,
+			"avgRefreshMillis": avgRefreshMs,
+		}
+	}
+	return snapshot
+}
+
+// handleCacheMetrics serves per-key cache metrics, mirroring /health and
+// /stats: an operational endpoint, not a signed/rate-limited dashboard one.
+func handleCacheMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"cache":     cacheMetricsSnapshot(),
+		"timestamp": time.Now().Unix(),
+	})
+}
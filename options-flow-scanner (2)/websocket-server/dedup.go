@@ -0,0 +1,114 @@
+// ================================================
+// POLYGON FAN-OUT DEDUPLICATION
+// ================================================
+// Polygon reconnects and endpoint failover can redeliver the same
+// trade/quote tick, and downstream (cache write, TimescaleDB write,
+// broadcast) pays for it twice. dedupTick checks an incoming tick against a
+// rolling pair of Bloom filters before handlePolygonMessage does any of
+// that work - one "current" and one "previous" window, staggered so a tick
+// near a rotation boundary is still caught by whichever filter last saw it.
+// False positives just mean an occasional legitimate tick gets dropped, so
+// DEDUP_FALSE_POSITIVE_RATE should stay very small.
+// ================================================
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/willf/bloom"
+)
+
+var (
+	dedupWindow                 = getEnvDuration("DEDUP_WINDOW", 30*time.Second)
+	dedupExpectedTicksPerWindow = getEnvInt("DEDUP_EXPECTED_TICKS", 200_000)
+	dedupFalsePositiveRate      = getEnvFloat("DEDUP_FALSE_POSITIVE_RATE", 0.001)
+
+	duplicatesSuppressedTotal int64
+)
+
+// tickDeduper suppresses redelivered Polygon ticks using two Bloom filters
+// rotated on a timer: `current` accumulates this window's keys, `previous`
+// is last window's filter, kept alive so a tick can't slip through right at
+// the rotation boundary. A tick is a duplicate if either filter has seen it.
+type tickDeduper struct {
+	mu       sync.RWMutex
+	current  *bloom.BloomFilter
+	previous *bloom.BloomFilter
+}
+
+func newTickDeduper() *tickDeduper {
+	d := &tickDeduper{
+		current:  newDedupFilter(),
+		previous: newDedupFilter(),
+	}
+	go d.rotateLoop()
+	return d
+}
+
+func newDedupFilter() *bloom.BloomFilter {
+	return bloom.NewWithEstimates(uint(dedupExpectedTicksPerWindow), dedupFalsePositiveRate)
+}
+
+func (d *tickDeduper) rotateLoop() {
+	ticker := time.NewTicker(dedupWindow)
+	defer ticker.Stop()
+	for range ticker.C {
+		d.mu.Lock()
+		d.previous = d.current
+		d.current = newDedupFilter()
+		d.mu.Unlock()
+	}
+}
+
+// seen reports whether key was already observed in the current or previous
+// window, inserting it into the current window's filter if not.
+func (d *tickDeduper) seen(key []byte) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.current.Test(key) || d.previous.Test(key) {
+		return true
+	}
+	d.current.Add(key)
+	return false
+}
+
+var polygonDeduper = newTickDeduper()
+
+// dedupKey builds the Bloom filter key for pm: the same trade/quote
+// redelivered by Polygon reproduces the exact same tuple, while two
+// distinct ticks at the same symbol+timestamp (different price/size/
+// exchange) stay distinguishable.
+func dedupKey(pm PolygonMessage) []byte {
+	return []byte(fmt.Sprintf("%s|%s|%d|%s|%s|%d",
+		pm.Symbol, pm.EventType, pm.Timestamp, pm.Price.String(), pm.Size.String(), pm.Exchange))
+}
+
+// isDuplicateTick reports whether pm was already delivered within the
+// current dedup window, bumping duplicatesSuppressedTotal on a hit. Only
+// trade/quote events carry enough identifying fields to dedup reliably;
+// aggregates and other event types always pass through.
+func isDuplicateTick(pm PolygonMessage) bool {
+	if pm.EventType != "T" && pm.EventType != "Q" {
+		return false
+	}
+
+	if polygonDeduper.seen(dedupKey(pm)) {
+		atomic.AddInt64(&duplicatesSuppressedTotal, 1)
+		return true
+	}
+	return false
+}
+
+// dedupStats renders the counters surfaced over /stats.
+func dedupStats() map[string]interface{} {
+	return map[string]interface{}{
+		"window_seconds":              dedupWindow.Seconds(),
+		"false_positive_rate":         dedupFalsePositiveRate,
+		"duplicates_suppressed_total": atomic.LoadInt64(&duplicatesSuppressedTotal),
+	}
+}
@@ -0,0 +1,197 @@
+// ================================================
+// FIXED-POINT DECIMAL VALUE
+// ================================================
+// Replaces float64 for prices, sizes, and percentages
+// so repeated arithmetic and JSON round-trips don't
+// drift. Backed by an int64 mantissa at a fixed scale.
+// ================================================
+
+package fixedpoint
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Scale is the number of decimal digits of precision kept by every Value.
+const Scale = 8
+
+var scaleFactor = int64(math.Pow10(Scale))
+
+// Value is a fixed-point decimal: mantissa / 10^Scale.
+type Value struct {
+	mantissa int64
+}
+
+// Zero is the additive identity.
+var Zero = Value{}
+
+// FromInt64 returns the Value representing the integer n.
+func FromInt64(n int64) Value {
+	return Value{mantissa: n * scaleFactor}
+}
+
+// FromFloat64 converts a float64 to a Value, rounding to Scale digits.
+// Prefer FromString/FromFloat64-via-wire-text when the source precision
+// matters (e.g. decoding an upstream API) since float64 itself may have
+// already lost precision before this is called.
+func FromFloat64(f float64) Value {
+	return Value{mantissa: int64(math.Round(f * float64(scaleFactor)))}
+}
+
+// FromString parses a decimal string (e.g. "123.45000001") into a Value
+// without round-tripping through float64, preserving the digits reported
+// by the source exactly (truncated beyond Scale digits).
+func FromString(s string) (Value, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Value{}, fmt.Errorf("fixedpoint: empty string")
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+	if intPart == "" {
+		intPart = "0"
+	}
+
+	// Truncate or pad the fractional part to exactly Scale digits.
+	if len(fracPart) > Scale {
+		fracPart = fracPart[:Scale]
+	} else {
+		fracPart = fracPart + strings.Repeat("0", Scale-len(fracPart))
+	}
+
+	intVal, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil {
+		return Value{}, fmt.Errorf("fixedpoint: invalid integer part %q: %w", intPart, err)
+	}
+	fracVal, err := strconv.ParseInt(fracPart, 10, 64)
+	if err != nil {
+		return Value{}, fmt.Errorf("fixedpoint: invalid fractional part %q: %w", fracPart, err)
+	}
+
+	mantissa := intVal*scaleFactor + fracVal
+	if negative {
+		mantissa = -mantissa
+	}
+
+	return Value{mantissa: mantissa}, nil
+}
+
+// Float64 returns the nearest float64 representation of v.
+func (v Value) Float64() float64 {
+	return float64(v.mantissa) / float64(scaleFactor)
+}
+
+// Add returns v + other.
+func (v Value) Add(other Value) Value {
+	return Value{mantissa: v.mantissa + other.mantissa}
+}
+
+// Sub returns v - other.
+func (v Value) Sub(other Value) Value {
+	return Value{mantissa: v.mantissa - other.mantissa}
+}
+
+// Mul returns v * other, computed via big.Int to avoid intermediate overflow.
+func (v Value) Mul(other Value) Value {
+	product := new(big.Int).Mul(big.NewInt(v.mantissa), big.NewInt(other.mantissa))
+	product.Div(product, big.NewInt(scaleFactor))
+	return Value{mantissa: product.Int64()}
+}
+
+// Div returns v / other. Division by zero returns Zero.
+func (v Value) Div(other Value) Value {
+	if other.mantissa == 0 {
+		return Zero
+	}
+	scaled := new(big.Int).Mul(big.NewInt(v.mantissa), big.NewInt(scaleFactor))
+	scaled.Div(scaled, big.NewInt(other.mantissa))
+	return Value{mantissa: scaled.Int64()}
+}
+
+// IsZero reports whether v is exactly zero.
+func (v Value) IsZero() bool {
+	return v.mantissa == 0
+}
+
+// Cmp compares v and other, returning -1 if v < other, 0 if v == other, and
+// 1 if v > other. Both share the same Scale, so this compares mantissas
+// directly rather than round-tripping through float64.
+func (v Value) Cmp(other Value) int {
+	switch {
+	case v.mantissa < other.mantissa:
+		return -1
+	case v.mantissa > other.mantissa:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// LessThan reports whether v < other.
+func (v Value) LessThan(other Value) bool {
+	return v.mantissa < other.mantissa
+}
+
+// GreaterThan reports whether v > other.
+func (v Value) GreaterThan(other Value) bool {
+	return v.mantissa > other.mantissa
+}
+
+// String renders v as a plain decimal string, e.g. "123.45000000".
+func (v Value) String() string {
+	mantissa := v.mantissa
+	negative := mantissa < 0
+	if negative {
+		mantissa = -mantissa
+	}
+
+	intPart := mantissa / scaleFactor
+	fracPart := mantissa % scaleFactor
+
+	sign := ""
+	if negative {
+		sign = "-"
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, intPart, Scale, fracPart)
+}
+
+// MarshalJSON emits v as a quoted decimal string, e.g. "\"123.45000000\"".
+func (v Value) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + v.String() + `"`), nil
+}
+
+// UnmarshalJSON accepts either a JSON number (preserving the literal digits
+// Polygon reported) or a quoted decimal string.
+func (v *Value) UnmarshalJSON(data []byte) error {
+	s := strings.TrimSpace(string(data))
+	if s == "null" {
+		*v = Zero
+		return nil
+	}
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+	}
+
+	parsed, err := FromString(s)
+	if err != nil {
+		return err
+	}
+	*v = parsed
+	return nil
+}
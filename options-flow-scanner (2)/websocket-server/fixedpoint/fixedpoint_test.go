@@ -0,0 +1,142 @@
+package fixedpoint
+
+import "testing"
+
+func TestFromString(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "integer", input: "123", want: "123.00000000"},
+		{name: "exact scale", input: "123.45000000", want: "123.45000000"},
+		{name: "truncates beyond scale", input: "123.450000019", want: "123.45000001"},
+		{name: "pads short fraction", input: "1.5", want: "1.50000000"},
+		{name: "negative", input: "-42.5", want: "-42.50000000"},
+		{name: "leading plus", input: "+42.5", want: "42.50000000"},
+		{name: "no integer part", input: ".5", want: "0.50000000"},
+		{name: "empty", input: "", wantErr: true},
+		{name: "garbage integer part", input: "abc.5", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromString(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("FromString(%q): expected error, got nil", tt.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FromString(%q): unexpected error: %v", tt.input, err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("FromString(%q) = %q, want %q", tt.input, got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	a, _ := FromString("10.5")
+	b, _ := FromString("3.25")
+
+	if got := a.Add(b).String(); got != "13.75000000" {
+		t.Errorf("Add = %s, want 13.75000000", got)
+	}
+	if got := a.Sub(b).String(); got != "7.25000000" {
+		t.Errorf("Sub = %s, want 7.25000000", got)
+	}
+	if got := a.Mul(b).String(); got != "34.12500000" {
+		t.Errorf("Mul = %s, want 34.12500000", got)
+	}
+	if got := a.Div(b).String(); got != "3.23076923" {
+		t.Errorf("Div = %s, want 3.23076923", got)
+	}
+	if got := a.Div(Zero); got != Zero {
+		t.Errorf("Div by zero = %v, want Zero", got)
+	}
+}
+
+func TestCmp(t *testing.T) {
+	low, _ := FromString("1.00000000")
+	high, _ := FromString("2.00000000")
+	equal, _ := FromString("1.00000000")
+
+	tests := []struct {
+		name string
+		a, b Value
+		want int
+	}{
+		{"less", low, high, -1},
+		{"greater", high, low, 1},
+		{"equal", low, equal, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.Cmp(tt.b); got != tt.want {
+				t.Errorf("Cmp = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	if !low.LessThan(high) {
+		t.Error("LessThan: expected low < high")
+	}
+	if low.LessThan(low) {
+		t.Error("LessThan: expected false for equal values")
+	}
+	if !high.GreaterThan(low) {
+		t.Error("GreaterThan: expected high > low")
+	}
+	if high.GreaterThan(high) {
+		t.Error("GreaterThan: expected false for equal values")
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	v, _ := FromString("99.99")
+
+	data, err := v.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON: unexpected error: %v", err)
+	}
+	if string(data) != `"99.99000000"` {
+		t.Errorf("MarshalJSON = %s, want \"99.99000000\"", data)
+	}
+
+	var got Value
+	if err := got.UnmarshalJSON(data); err != nil {
+		t.Fatalf("UnmarshalJSON(quoted): unexpected error: %v", err)
+	}
+	if got.Cmp(v) != 0 {
+		t.Errorf("UnmarshalJSON(quoted) = %s, want %s", got, v)
+	}
+
+	var fromNumber Value
+	if err := fromNumber.UnmarshalJSON([]byte("99.99")); err != nil {
+		t.Fatalf("UnmarshalJSON(number): unexpected error: %v", err)
+	}
+	if fromNumber.Cmp(v) != 0 {
+		t.Errorf("UnmarshalJSON(number) = %s, want %s", fromNumber, v)
+	}
+
+	var fromNull Value
+	if err := fromNull.UnmarshalJSON([]byte("null")); err != nil {
+		t.Fatalf("UnmarshalJSON(null): unexpected error: %v", err)
+	}
+	if fromNull != Zero {
+		t.Errorf("UnmarshalJSON(null) = %s, want Zero", fromNull)
+	}
+}
+
+func TestIsZero(t *testing.T) {
+	if !Zero.IsZero() {
+		t.Error("Zero.IsZero() = false, want true")
+	}
+	nonZero, _ := FromString("0.00000001")
+	if nonZero.IsZero() {
+		t.Error("nonZero.IsZero() = true, want false")
+	}
+}
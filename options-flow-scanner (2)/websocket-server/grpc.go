@@ -0,0 +1,164 @@
+// ================================================
+// GRPC TRANSPORT SERVICE
+// ================================================
+// MarketData.Subscribe is the gRPC equivalent of /ws for callers that want
+// a typed, HTTP/2-multiplexed stream instead of a raw socket (another
+// service in the cluster, a server-to-server integration). It builds the
+// same *Client the WebSocket and SSE handlers do, forced onto the protobuf
+// codec since the wire format here is already a typed proto stream rather
+// than a negotiated subprotocol.
+// ================================================
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"nexuralflow/websocket-server/pb"
+	"nexuralflow/websocket-server/wsauth"
+)
+
+// grpcServer implements pb.MarketDataServer.
+type grpcServer struct {
+	pb.UnimplementedMarketDataServer
+}
+
+// Subscribe services one MarketData.Subscribe RPC for its whole lifetime:
+// incoming SubscriptionRequests drive subscribe/unsubscribe directly
+// (there's no byte-oriented handleMessage decode step, since the stream is
+// already typed), and writePump drains outbound frames onto it until the
+// peer disconnects or calls Close via its Transport.
+func (grpcServer) Subscribe(stream pb.MarketData_SubscribeServer) error {
+	claims, err := authenticateGRPC(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	transport := newGRPCTransport(stream)
+	client := &Client{
+		transport:     transport,
+		queue:         newOutboundQueue(),
+		budget:        newWriteBudget(),
+		codec:         protobufCodec{},
+		compressor:    identityCompressor{},
+		claims:        claims,
+		subscriptions: make(map[string]bool),
+		symbols:       make(map[string]int),
+		symbolsByKey:  make(map[string][]string),
+		id:            generateClientID(),
+		lastSeen:      time.Now(),
+	}
+
+	wsRegistry.Track(claims.JTI, transport)
+	registerClient(client)
+	defer func() {
+		wsRegistry.Untrack(claims.JTI, transport)
+		unregisterClient(client)
+		log.Printf("👋 gRPC client disconnected: %s (Total: %d)", client.id, getClientCount())
+	}()
+
+	log.Printf("✅ New gRPC client connected: %s (tenant: %s, total: %d)",
+		client.id, client.claims.Tenant, getClientCount())
+
+	go client.writePump()
+
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			client.lastSeen = time.Now()
+			switch req.Type {
+			case "subscribe":
+				client.subscribe(req.Channel, req.Symbols)
+			case "unsubscribe":
+				client.unsubscribe(req.Channel, req.Symbols)
+			case "history":
+				client.sendHistory(req.Channel, int(req.Limit))
+			default:
+				log.Printf("⚠️  Unknown message type from %s: %s", client.id, req.Type)
+			}
+		}
+	}()
+
+	select {
+	case err := <-recvErr:
+		return err
+	case <-transport.done:
+		return nil
+	}
+}
+
+// authenticateGRPC applies the same bearer-token rule authenticateWebSocket
+// does for /ws, reading the token from gRPC metadata instead of HTTP
+// headers/query parameters.
+func authenticateGRPC(ctx context.Context) (wsauth.Claims, error) {
+	if wsVerifier == nil {
+		return wsauth.Claims{}, nil
+	}
+
+	token := tokenFromGRPCMetadata(ctx)
+	if token == "" {
+		return wsauth.Claims{}, fmt.Errorf("missing bearer token")
+	}
+
+	claims, err := wsVerifier.Verify(ctx, token)
+	if err != nil {
+		return wsauth.Claims{}, err
+	}
+
+	if wsRegistry.IsRevoked(claims.JTI) {
+		return wsauth.Claims{}, fmt.Errorf("token revoked")
+	}
+
+	return claims, nil
+}
+
+// tokenFromGRPCMetadata reads the bearer token off the "authorization"
+// metadata key, mirroring the Authorization header ExtractToken reads for
+// WebSocket/REST.
+func tokenFromGRPCMetadata(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return ""
+	}
+	const prefix = "Bearer "
+	if len(values[0]) > len(prefix) && values[0][:len(prefix)] == prefix {
+		return values[0][len(prefix):]
+	}
+	return values[0]
+}
+
+// startGRPCServer runs the MarketData gRPC service until the process exits.
+// It's started as its own background goroutine from main, alongside the
+// HTTP listener the WebSocket/SSE/REST handlers share.
+func startGRPCServer() {
+	port := getEnv("GRPC_PORT", "50051")
+	lis, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		log.Printf("⚠️  gRPC listener failed on port %s: %v (continuing without gRPC transport)", port, err)
+		return
+	}
+
+	server := grpc.NewServer()
+	pb.RegisterMarketDataServer(server, grpcServer{})
+
+	log.Printf("🚀 gRPC MarketData service starting on port %s", port)
+	if err := server.Serve(lis); err != nil {
+		log.Printf("⚠️  gRPC server stopped: %v", err)
+	}
+}
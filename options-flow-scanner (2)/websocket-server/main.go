@@ -21,6 +21,10 @@ import (
 	"github.com/joho/godotenv"
 	"github.com/redis/go-redis/v9"
 	"golang.org/x/net/context"
+
+	"nexuralflow/websocket-server/auth"
+	"nexuralflow/websocket-server/ratelimit"
+	"nexuralflow/websocket-server/wsauth"
 )
 
 // ================================================
@@ -30,8 +34,10 @@ import (
 var (
 	// WebSocket upgrader with production settings
 	upgrader = websocket.Upgrader{
-		ReadBufferSize:  1024,
-		WriteBufferSize: 1024,
+		ReadBufferSize:    1024,
+		WriteBufferSize:   1024,
+		EnableCompression: true, // negotiates permessage-deflate when the client offers it
+		Subprotocols:      negotiatedSubprotocols,
 		CheckOrigin: func(r *http.Request) bool {
 			// In production, validate origin properly
 			origin := r.Header.Get("Origin")
@@ -51,6 +57,10 @@ var (
 	// Polygon client for real-time market data
 	polygonClient *PolygonClient
 
+	// Cluster broker - fans messages out across every server instance and
+	// tracks channel presence so /stats reflects the whole fleet
+	broker Broker
+
 	// Connection management
 	clients     = make(map[*Client]bool)
 	clientsLock sync.RWMutex
@@ -65,17 +75,44 @@ var (
 	// Polygon subscriptions tracking
 	polygonSymbols     = make(map[string]int) // symbol -> client count
 	polygonSymbolsLock sync.RWMutex
+
+	// REST API auth + rate limiting
+	apiVerifier = auth.NewVerifier(auth.NewEnvKeyStore("NF_API_KEYS"))
+	apiLimiter  = ratelimit.NewLimiter(100, time.Minute)
+
+	// WebSocket JWT auth + revocation. wsVerifier is nil (auth disabled,
+	// pre-existing open behavior) unless WS_JWKS_URL is set; operators with
+	// their own OIDC provider (Auth0/Okta/Keycloak or a custom introspection
+	// endpoint) swap this var for their own wsauth.Verifier implementation.
+	wsVerifier wsauth.Verifier = buildWSVerifier()
+	wsRegistry                 = wsauth.NewRegistry()
 )
 
+// buildWSVerifier wires up the default JWKS-backed verifier from env config.
+func buildWSVerifier() wsauth.Verifier {
+	jwksURL := getEnv("WS_JWKS_URL", "")
+	if jwksURL == "" {
+		return nil
+	}
+	return wsauth.NewJWKSVerifier(jwksURL, 10*time.Minute)
+}
+
 // ================================================
 // DATA STRUCTURES
 // ================================================
 
 // Client represents a WebSocket connection
 type Client struct {
-	conn          *websocket.Conn
-	send          chan []byte
+	transport     Transport      // WebSocket, SSE, or gRPC - see transport.go
+	queue         *outboundQueue // backpressure-aware replacement for a raw send channel
+	budget        *writeBudget   // per-client bytes/sec + msgs/sec token bucket
+	codec         Codec          // negotiated via Sec-WebSocket-Protocol, default JSON
+	compressor    Compressor     // negotiated "+zstd" suffix, default identity
+	claims        wsauth.Claims  // zero value when wsVerifier is nil (auth disabled)
 	subscriptions map[string]bool
+	symbols       map[string]int      // symbol -> number of active subscription keys referencing it, for MaxSymbols quota enforcement
+	symbolsByKey  map[string][]string // subscription key -> symbols it added to c.symbols, so unsubscribe can undo exactly what subscribe did
+	replay        *replaySession      // active "replay" stream, if any - see replay.go
 	mu            sync.RWMutex
 	id            string
 	lastSeen      time.Time
@@ -91,11 +128,18 @@ type Message struct {
 	Metadata  map[string]interface{} `json:"metadata,omitempty"`
 }
 
-// SubscriptionRequest represents a subscription message
+// SubscriptionRequest represents a subscription message. From/To/Speed are
+// only meaningful for a "replay" request: From/To are unix millis bounding
+// the replay window, and Speed is the playback speed multiplier (1.0 =
+// real-time, 0 defaults to 1.0).
 type SubscriptionRequest struct {
 	Type    string   `json:"type"`
 	Channel string   `json:"channel"`
 	Symbols []string `json:"symbols"`
+	Limit   int      `json:"limit,omitempty"`
+	From    int64    `json:"from,omitempty"`
+	To      int64    `json:"to,omitempty"`
+	Speed   float64  `json:"speed,omitempty"`
 }
 
 // ================================================
@@ -108,7 +152,15 @@ func main() {
 
 	// Initialize Redis
 	initRedis()
-	
+
+	// Initialize the cluster broker - Redis-backed when Redis is up, so
+	// multiple instances share subscriptions/fan-out; in-memory otherwise
+	if redisClient != nil {
+		broker = NewRedisBroker(redisClient, dispatchToLocalSubscribers)
+	} else {
+		broker = NewInMemoryBroker(dispatchToLocalSubscribers)
+	}
+
 	// Initialize TimescaleDB
 	if err := InitDatabase(); err != nil {
 		log.Printf("⚠️  TimescaleDB initialization failed: %v (continuing without database)", err)
@@ -127,24 +179,38 @@ func main() {
 	// Initialize Polygon client
 	initPolygon()
 
+	// Start the OHLCV kline aggregator
+	initKlineAggregator()
+
 	// Start background services
 	go handleMessages()
 	go startHeartbeat()
 	// Redis pub/sub disabled - using DragonflyDB for caching only
 	// go startRedisSubscriber()
+	go startCacheInvalidationSubscriber()
+	go startCacheExpiryInvalidator(context.Background())
+	go startFlowStreamTrimmer(context.Background())
 	go cleanupStaleConnections()
+	go wsRegistry.StartSweeper(context.Background(), time.Hour)
+	go startGRPCServer()
 
 	// Setup HTTP routes
 	http.HandleFunc("/ws", handleWebSocket)
+	http.HandleFunc("/sse/", handleSSE)
 	http.HandleFunc("/health", handleHealth)
 	http.HandleFunc("/stats", handleStats)
+	http.HandleFunc("/metrics", handleCacheMetrics)
 	
-	// REST API routes for dashboard
-	http.HandleFunc("/api/portfolio/summary", handlePortfolioSummary)
-	http.HandleFunc("/api/watchlist", handleWatchlist)
-	http.HandleFunc("/api/market/pulse", handleMarketPulse)
-	http.HandleFunc("/api/portfolio/snapshot", handlePortfolioSnapshot)
-	http.HandleFunc("/api/opportunities/today", handleTodaysOpportunities)
+	// REST API routes for dashboard (signed + rate-limited)
+	http.HandleFunc("/api/portfolio/summary", withAPIMiddleware(handlePortfolioSummary))
+	http.HandleFunc("/api/watchlist", withAPIMiddleware(handleWatchlist))
+	http.HandleFunc("/api/market/pulse", withAPIMiddleware(handleMarketPulse))
+	http.HandleFunc("/api/portfolio/snapshot", withAPIMiddleware(handlePortfolioSnapshot))
+	http.HandleFunc("/api/opportunities/today", withAPIMiddleware(handleTodaysOpportunities))
+	http.HandleFunc("/api/klines", withAPIMiddleware(handleKlines))
+
+	// Admin endpoint - force-closes and blocklists a revoked WebSocket token
+	http.HandleFunc("/admin/tokens/revoke", withAPIMiddleware(handleRevokeToken))
 
 	// Start server
 	port := getEnv("PORT", "8080")
@@ -188,6 +254,15 @@ func initRedis() {
 // ================================================
 
 func handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	// Authenticate before upgrading, so a rejected token gets a plain HTTP
+	// 401 instead of an upgraded socket that immediately closes.
+	claims, err := authenticateWebSocket(r)
+	if err != nil {
+		log.Printf("🔒 WebSocket auth rejected: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
 	// Upgrade connection
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -195,48 +270,95 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	codec, compressor := resolveCodec(conn.Subprotocol())
+
+	budget := newWriteBudget()
+	if claims.MaxMsgsPerSec > 0 {
+		budget = newWriteBudgetWithLimits(bytesPerSecBudget, claims.MaxMsgsPerSec)
+	}
+
+	transport := &wsTransport{conn: conn}
+
 	// Create client
 	client := &Client{
-		conn:          conn,
-		send:          make(chan []byte, 256),
+		transport:     transport,
+		queue:         newOutboundQueue(),
+		budget:        budget,
+		codec:         codec,
+		compressor:    compressor,
+		claims:        claims,
 		subscriptions: make(map[string]bool),
+		symbols:       make(map[string]int),
+		symbolsByKey:  make(map[string][]string),
 		id:            generateClientID(),
 		lastSeen:      time.Now(),
 	}
 
+	wsRegistry.Track(claims.JTI, transport)
+
 	// Register client
 	registerClient(client)
 
 	// Log connection
-	log.Printf("✅ New client connected: %s (Total: %d)", client.id, getClientCount())
+	log.Printf("✅ New client connected: %s (tenant: %s, codec: %s, compression: %s, total: %d)",
+		client.id, client.claims.Tenant, client.codec.Name(), client.compressor.Name(), getClientCount())
 
 	// Start goroutines
 	go client.writePump()
-	go client.readPump()
+	go wsReadPump(client, conn)
+}
+
+// authenticateWebSocket verifies the bearer token on r when wsVerifier is
+// configured. With no verifier configured (WS_JWKS_URL unset and no custom
+// Verifier assigned), auth is disabled and every connection gets the
+// zero-value Claims, matching the pre-auth behavior.
+func authenticateWebSocket(r *http.Request) (wsauth.Claims, error) {
+	if wsVerifier == nil {
+		return wsauth.Claims{}, nil
+	}
+
+	token := wsauth.ExtractToken(r)
+	if token == "" {
+		return wsauth.Claims{}, fmt.Errorf("missing bearer token")
+	}
+
+	claims, err := wsVerifier.Verify(r.Context(), token)
+	if err != nil {
+		return wsauth.Claims{}, err
+	}
+
+	if wsRegistry.IsRevoked(claims.JTI) {
+		return wsauth.Claims{}, fmt.Errorf("token revoked")
+	}
+
+	return claims, nil
 }
 
 // ================================================
 // CLIENT METHODS
 // ================================================
 
-// readPump reads messages from WebSocket
-func (c *Client) readPump() {
+// wsReadPump reads messages from the WebSocket connection backing client.
+// SSE has no read side and gRPC's bidi stream reads typed SubscribeReq
+// messages directly, so this stays WebSocket-specific rather than living on
+// Transport.
+func wsReadPump(client *Client, conn *websocket.Conn) {
 	defer func() {
-		unregisterClient(c)
-		c.conn.Close()
-		log.Printf("👋 Client disconnected: %s (Total: %d)", c.id, getClientCount())
+		unregisterClient(client)
+		conn.Close()
+		log.Printf("👋 Client disconnected: %s (Total: %d)", client.id, getClientCount())
 	}()
 
 	// Set read deadline
-	c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-	c.conn.SetPongHandler(func(string) error {
-		c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-		c.lastSeen = time.Now()
+	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		client.lastSeen = time.Now()
 		return nil
 	})
 
 	for {
-		_, message, err := c.conn.ReadMessage()
+		_, message, err := conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("❌ WebSocket error: %v", err)
@@ -245,60 +367,61 @@ func (c *Client) readPump() {
 		}
 
 		// Update last seen
-		c.lastSeen = time.Now()
+		client.lastSeen = time.Now()
 
 		// Handle message
-		go c.handleMessage(message)
+		go client.handleMessage(message)
 	}
 }
 
-// writePump writes messages to WebSocket
+// writePump drains the client's outbound queue onto its Transport. It's
+// shared by every transport: WriteFrames/Close hide the protocol-specific
+// wire details, and the heartbeat tick is skipped for transports that don't
+// implement Pinger.
 func (c *Client) writePump() {
 	ticker := time.NewTicker(30 * time.Second)
 	defer func() {
 		ticker.Stop()
-		c.conn.Close()
+		c.transport.Close()
 	}()
 
 	for {
 		select {
-		case message, ok := <-c.send:
-			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
+		case <-c.queue.done:
+			c.transport.Close()
+			return
+
+		case <-c.queue.notify:
+			items := c.queue.drain()
+			if len(items) == 0 {
+				continue
 			}
 
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
-			}
-			w.Write(message)
-
-			// Add queued messages to current WebSocket frame
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-c.send)
-			}
-
-			if err := w.Close(); err != nil {
+			if err := c.transport.WriteFrames(items, c.codec.BinaryFrame()); err != nil {
 				return
 			}
 
 		case <-ticker.C:
-			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-				return
+			if pinger, ok := c.transport.(Pinger); ok {
+				if err := pinger.Ping(); err != nil {
+					return
+				}
 			}
 		}
 	}
 }
 
-// handleMessage processes incoming client messages
+// handleMessage processes incoming client messages, decoding them with the
+// codec negotiated for this connection.
 func (c *Client) handleMessage(message []byte) {
-	var req SubscriptionRequest
-	if err := json.Unmarshal(message, &req); err != nil {
+	raw, err := c.compressor.Decompress(message)
+	if err != nil {
+		log.Printf("❌ Failed to decompress message from %s: %v", c.id, err)
+		return
+	}
+
+	req, err := c.codec.DecodeSubscriptionRequest(raw)
+	if err != nil {
 		log.Printf("❌ Invalid message from %s: %v", c.id, err)
 		return
 	}
@@ -309,7 +432,7 @@ func (c *Client) handleMessage(message []byte) {
 		log.Printf("📥 Client %s subscribed to %s: %v", c.id, req.Channel, req.Symbols)
 
 	case "unsubscribe":
-		c.unsubscribe(req.Channel)
+		c.unsubscribe(req.Channel, req.Symbols)
 		log.Printf("📤 Client %s unsubscribed from %s", c.id, req.Channel)
 
 	case "ping":
@@ -318,19 +441,63 @@ func (c *Client) handleMessage(message []byte) {
 			Timestamp: time.Now().UnixMilli(),
 		})
 
+	case "history":
+		c.sendHistory(req.Channel, req.Limit)
+
+	case "replay":
+		c.startReplay(req)
+		log.Printf("🎬 Client %s started replay on %s: %v", c.id, req.Channel, req.Symbols)
+
+	case "pause", "resume", "seek":
+		c.controlReplay(req.Type, req)
+
 	default:
 		log.Printf("⚠️  Unknown message type from %s: %s", c.id, req.Type)
 	}
 }
 
-// subscribe adds subscription for client
+// subscribe adds subscription for client, enforcing the channel scope and
+// symbol quota carried in the connection's JWT claims (see wsauth.Claims).
 func (c *Client) subscribe(channel string, symbols []string) {
+	if !c.claims.AllowsChannel(channel) {
+		c.sendMessage(Message{
+			Type:      "error",
+			Channel:   channel,
+			Data:      map[string]string{"reason": "channel not permitted for this token"},
+			Timestamp: time.Now().UnixMilli(),
+		})
+		return
+	}
+
 	c.mu.Lock()
+	if c.claims.MaxSymbols > 0 {
+		newSymbols := 0
+		for _, symbol := range symbols {
+			if c.symbols[symbol] == 0 {
+				newSymbols++
+			}
+		}
+		if len(c.symbols)+newSymbols > c.claims.MaxSymbols {
+			c.mu.Unlock()
+			c.sendMessage(Message{
+				Type:      "error",
+				Channel:   channel,
+				Data:      map[string]string{"reason": "symbol quota exceeded for this plan"},
+				Timestamp: time.Now().UnixMilli(),
+			})
+			return
+		}
+	}
+
 	key := channel
 	if len(symbols) > 0 {
 		key = channel + ":" + symbols[0] // Simplified for now
 	}
 	c.subscriptions[key] = true
+	c.symbolsByKey[key] = symbols
+	for _, symbol := range symbols {
+		c.symbols[symbol]++
+	}
 	c.mu.Unlock()
 
 	// Add to global subscriptions
@@ -340,7 +507,15 @@ func (c *Client) subscribe(channel string, symbols []string) {
 	}
 	subscriptions[key][c] = true
 	subscriptionsLock.Unlock()
-	
+
+	// Tell the broker this node now has a local subscriber for key, so
+	// fan-out published by any node in the fleet reaches us
+	if broker != nil {
+		if err := broker.Subscribe(key); err != nil {
+			log.Printf("⚠️  Broker subscribe failed for %s: %v", key, err)
+		}
+	}
+
 	// Subscribe to Polygon for real-time market data
 	if len(symbols) > 0 && (channel == "trades" || channel == "quotes" || channel == "market-data") {
 		subscribeToPolygon(symbols)
@@ -355,19 +530,45 @@ func (c *Client) subscribe(channel string, symbols []string) {
 	})
 }
 
-// unsubscribe removes subscription for client
-func (c *Client) unsubscribe(channel string) {
+// unsubscribe removes subscription for client. symbols must match what was
+// passed to the subscribe() call that created it, so the same key is
+// recomputed and c.symbols' refcounts are decremented for exactly the
+// symbols that subscription added.
+func (c *Client) unsubscribe(channel string, symbols []string) {
+	key := channel
+	if len(symbols) > 0 {
+		key = channel + ":" + symbols[0] // matches the key subscribe() computed
+	}
+
 	c.mu.Lock()
-	delete(c.subscriptions, channel)
+	delete(c.subscriptions, key)
+	for _, symbol := range c.symbolsByKey[key] {
+		if c.symbols[symbol] > 0 {
+			c.symbols[symbol]--
+			if c.symbols[symbol] == 0 {
+				delete(c.symbols, symbol)
+			}
+		}
+	}
+	delete(c.symbolsByKey, key)
 	c.mu.Unlock()
 
-	// Remove from global subscriptions
+	// Remove from global subscriptions, and tell the broker once nobody on
+	// this node is listening anymore so it stops fanning the channel in
 	subscriptionsLock.Lock()
-	if subscriptions[channel] != nil {
-		delete(subscriptions[channel], c)
+	empty := false
+	if subscriptions[key] != nil {
+		delete(subscriptions[key], c)
+		empty = len(subscriptions[key]) == 0
 	}
 	subscriptionsLock.Unlock()
 
+	if empty && broker != nil {
+		if err := broker.Unsubscribe(key); err != nil {
+			log.Printf("⚠️  Broker unsubscribe failed for %s: %v", key, err)
+		}
+	}
+
 	// Send confirmation
 	c.sendMessage(Message{
 		Type:      "unsubscribed",
@@ -376,20 +577,55 @@ func (c *Client) unsubscribe(channel string) {
 	})
 }
 
-// sendMessage sends a message to client
+// sendHistory replies with up to limit of the most recent messages on
+// channel, so a client reconnecting after a brief drop can catch up instead
+// of waiting on the next live update.
+func (c *Client) sendHistory(channel string, limit int) {
+	if limit <= 0 {
+		limit = 50
+	}
+
+	if broker == nil {
+		c.sendMessage(Message{Type: "history", Channel: channel, Data: []Message{}, Timestamp: time.Now().UnixMilli()})
+		return
+	}
+
+	messages, err := broker.History(channel, limit)
+	if err != nil {
+		log.Printf("⚠️  History lookup failed for %s: %v", channel, err)
+		messages = []Message{}
+	}
+
+	c.sendMessage(Message{
+		Type:      "history",
+		Channel:   channel,
+		Data:      messages,
+		Timestamp: time.Now().UnixMilli(),
+	})
+}
+
+// sendMessage sends a message to client, encoded with its negotiated codec
+// and compression and subject to its write budget and backpressure policy
+// (see enqueue).
 func (c *Client) sendMessage(msg Message) {
-	data, err := json.Marshal(msg)
+	data, err := c.codec.EncodeMessage(msg)
 	if err != nil {
-		log.Printf("❌ Failed to marshal message: %v", err)
+		log.Printf("❌ Failed to encode message for %s (%s): %v", c.id, c.codec.Name(), err)
 		return
 	}
 
-	select {
-	case c.send <- data:
-	default:
-		// Channel full, client too slow
-		log.Printf("⚠️  Client %s send buffer full, dropping message", c.id)
+	data, err = c.compressor.Compress(data)
+	if err != nil {
+		log.Printf("❌ Failed to compress message for %s: %v", c.id, err)
+		return
 	}
+
+	symbol := ""
+	if len(msg.Symbols) > 0 {
+		symbol = msg.Symbols[0]
+	}
+
+	c.enqueue(data, symbol)
 }
 
 // ================================================
@@ -400,22 +636,45 @@ func registerClient(client *Client) {
 	clientsLock.Lock()
 	clients[client] = true
 	clientsLock.Unlock()
+
+	if broker != nil {
+		if err := broker.TrackConnect(); err != nil {
+			log.Printf("⚠️  Broker connect tracking failed: %v", err)
+		}
+	}
 }
 
 func unregisterClient(client *Client) {
 	clientsLock.Lock()
 	if _, ok := clients[client]; ok {
 		delete(clients, client)
-		close(client.send)
+		client.queue.close()
+		wsRegistry.Untrack(client.claims.JTI, client.transport)
 
-		// Remove from all subscriptions
+		// Remove from all subscriptions, unsubscribing from the broker for
+		// any channel this client was the last local listener on
 		subscriptionsLock.Lock()
+		emptied := make([]string, 0, len(client.subscriptions))
 		for channel := range client.subscriptions {
 			if subscriptions[channel] != nil {
 				delete(subscriptions[channel], client)
+				if len(subscriptions[channel]) == 0 {
+					emptied = append(emptied, channel)
+				}
 			}
 		}
 		subscriptionsLock.Unlock()
+
+		if broker != nil {
+			for _, channel := range emptied {
+				if err := broker.Unsubscribe(channel); err != nil {
+					log.Printf("⚠️  Broker unsubscribe failed for %s: %v", channel, err)
+				}
+			}
+			if err := broker.TrackDisconnect(); err != nil {
+				log.Printf("⚠️  Broker disconnect tracking failed: %v", err)
+			}
+		}
 	}
 	clientsLock.Unlock()
 }
@@ -432,25 +691,66 @@ func getClientCount() int {
 
 func handleMessages() {
 	for msg := range broadcast {
-		data, err := json.Marshal(msg)
-		if err != nil {
-			log.Printf("❌ Failed to marshal broadcast: %v", err)
+		if broker == nil {
+			dispatchToLocalSubscribers(msg)
 			continue
 		}
 
-		// Broadcast to subscribed clients
-		subscriptionsLock.RLock()
-		channel := msg.Channel
-		if subscribedClients, ok := subscriptions[channel]; ok {
-			for client := range subscribedClients {
-				select {
-				case client.send <- data:
-				default:
-					// Client buffer full, skip
-				}
+		if err := broker.Publish(msg); err != nil {
+			log.Printf("❌ Broker publish failed for %s: %v", msg.Channel, err)
+		}
+	}
+}
+
+// encodedFrame caches the wire bytes for one codec+compression combination,
+// so a broadcast to N subscribers on the same codec marshals once instead of
+// N times.
+type encodedFrame struct {
+	data []byte
+	err  error
+}
+
+// dispatchToLocalSubscribers delivers msg to every client on this node
+// subscribed to msg.Channel. This is the Broker's local fan-out callback -
+// for the in-memory Broker it runs synchronously from Publish; for the
+// Redis Broker it runs once per node, fed by that node's pub/sub subscription.
+//
+// Subscribers can be on different negotiated codecs/compression, so msg is
+// marshaled once per distinct (codec, compressor) pair seen among them, not
+// once per subscriber.
+func dispatchToLocalSubscribers(msg Message) {
+	symbol := ""
+	if len(msg.Symbols) > 0 {
+		symbol = msg.Symbols[0]
+	}
+
+	frames := make(map[string]encodedFrame)
+
+	subscriptionsLock.RLock()
+	defer subscriptionsLock.RUnlock()
+	subscribedClients, ok := subscriptions[msg.Channel]
+	if !ok {
+		return
+	}
+
+	for client := range subscribedClients {
+		key := client.codec.Name() + "|" + client.compressor.Name()
+		frame, cached := frames[key]
+		if !cached {
+			data, err := client.codec.EncodeMessage(msg)
+			if err == nil {
+				data, err = client.compressor.Compress(data)
 			}
+			frame = encodedFrame{data: data, err: err}
+			frames[key] = frame
+		}
+
+		if frame.err != nil {
+			log.Printf("❌ Failed to encode broadcast for codec %s: %v", key, frame.err)
+			continue
 		}
-		subscriptionsLock.RUnlock()
+
+		client.enqueue(frame.data, symbol)
 	}
 }
 
@@ -518,13 +818,27 @@ func handleStats(w http.ResponseWriter, r *http.Request) {
 	channelCount := len(subscriptions)
 	subscriptionsLock.RUnlock()
 
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	response := map[string]interface{}{
 		"clients":       getClientCount(),
 		"channels":      channelCount,
 		"uptime":        time.Since(startTime).Seconds(),
 		"redis_enabled": redisClient != nil,
 		"timestamp":     time.Now().Unix(),
-	})
+		"backpressure":  backpressureStats(),
+		"dedup":         dedupStats(),
+	}
+
+	// Cluster-wide presence, summed across every node in the fleet - not
+	// just whichever pod happens to answer this request
+	if broker != nil {
+		if clusterStats, err := broker.Stats(); err == nil {
+			response["cluster"] = clusterStats
+		} else {
+			log.Printf("⚠️  Failed to gather cluster stats: %v", err)
+		}
+	}
+
+	json.NewEncoder(w).Encode(response)
 }
 
 // ================================================
@@ -562,7 +876,7 @@ func cleanupStaleConnections() {
 		// Close stale connections
 		for _, client := range stale {
 			log.Printf("🧹 Cleaning up stale client: %s", client.id)
-			client.conn.Close()
+			client.transport.Close()
 		}
 	}
 }
@@ -605,7 +919,7 @@ func initPolygon() {
 	}
 	
 	// Create Polygon client with message handler
-	polygonClient = NewPolygonClient(apiKey, handlePolygonMessage)
+	polygonClient = NewPolygonClient(apiKey, ClusterStocks, handlePolygonMessage)
 	
 	// Connect to Polygon
 	if err := polygonClient.Connect(); err != nil {
@@ -626,20 +940,37 @@ func initPolygon() {
 
 // handlePolygonMessage handles messages from Polygon WebSocket
 func handlePolygonMessage(pm PolygonMessage) {
+	// Reconnects/failover can redeliver the same tick - drop it before it
+	// pays for a duplicate cache write, TimescaleDB write, and broadcast.
+	if isDuplicateTick(pm) {
+		return
+	}
+
 	// Transform Polygon message to our format
 	msg := TransformPolygonMessage(pm)
 	
 	// ================================================
 	// DUAL-WRITE PATTERN: DragonflyDB + TimescaleDB
 	// ================================================
-	
+
+	// Fold trades into the OHLCV kline aggregator
+	if pm.EventType == "T" && pm.Symbol != "" && pm.Price.Float64() > 0 && klineAggregator != nil {
+		klineAggregator.Ingest(pm.Symbol, pm.Price, pm.Size, time.UnixMilli(pm.Timestamp))
+	}
+
+	// A new trade for a symbol that feeds the dashboard invalidates the
+	// derived cache keys on every server instance.
+	if pm.EventType == "T" && isWatchlistSymbol(pm.Symbol) {
+		InvalidateSymbol(pm.Symbol, cacheKeyWatchlist, cacheKeyMarketPulse)
+	}
+
 	// 1. Write to DragonflyDB (cache - hot data, fast)
 	go func() {
 		if IsCacheReady() && pm.Symbol != "" {
 			// Cache trade data
-			if pm.EventType == "T" && pm.Price > 0 {
+			if pm.EventType == "T" && pm.Price.Float64() > 0 {
 				// Cache latest price
-				if err := CachePrice(pm.Symbol, pm.Price); err != nil {
+				if err := CachePrice(pm.Symbol, pm.Price.Float64()); err != nil {
 					log.Printf("⚠️  Failed to cache price for %s: %v", pm.Symbol, err)
 				}
 				
@@ -655,7 +986,7 @@ func handlePolygonMessage(pm PolygonMessage) {
 				}
 				
 				// Cache options flow if this is options data
-				if pm.Size > 0 {
+				if pm.Size.Float64() > 0 {
 					flowData := map[string]interface{}{
 						"time":      pm.Timestamp,
 						"price":     pm.Price,
@@ -708,7 +1039,7 @@ func handlePolygonMessage(pm PolygonMessage) {
 			defer cancel()
 			
 			// Write trade data to TimescaleDB
-			if pm.EventType == "T" && pm.Symbol != "" && pm.Price > 0 {
+			if pm.EventType == "T" && pm.Symbol != "" && pm.Price.Float64() > 0 {
 				exchangeStr := fmt.Sprintf("EX%d", pm.Exchange)
 				err := WriteTrade(writeCtx, pm.Symbol, pm.Price, pm.Size, exchangeStr, time.UnixMilli(pm.Timestamp))
 				if err != nil {
@@ -0,0 +1,51 @@
+// ================================================
+// GRPC WIRE CODEC FOR HAND-ROLLED MESSAGES
+// ================================================
+// grpc-go's built-in "proto" codec expects google.golang.org/protobuf
+// messages (ProtoReflect() and friends); Message/SubscriptionRequest only
+// implement the older gogo-style Marshal()/Unmarshal() pair (see
+// message.pb.go). Registering this codec under the same "proto" name lets
+// MarketData's generated stream wrappers call grpc.ClientStream.SendMsg /
+// RecvMsg directly with our types instead of hand-marshaling to []byte at
+// every call site.
+// ================================================
+
+package pb
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc/encoding"
+)
+
+type marshaler interface {
+	Marshal() ([]byte, error)
+}
+
+type unmarshaler interface {
+	Unmarshal([]byte) error
+}
+
+type gogoCodec struct{}
+
+func (gogoCodec) Name() string { return "proto" }
+
+func (gogoCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(marshaler)
+	if !ok {
+		return nil, fmt.Errorf("pb: %T does not implement Marshal() ([]byte, error)", v)
+	}
+	return m.Marshal()
+}
+
+func (gogoCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(unmarshaler)
+	if !ok {
+		return fmt.Errorf("pb: %T does not implement Unmarshal([]byte) error", v)
+	}
+	return m.Unmarshal(data)
+}
+
+func init() {
+	encoding.RegisterCodec(gogoCodec{})
+}
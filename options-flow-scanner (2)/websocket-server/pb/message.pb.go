@@ -0,0 +1,245 @@
+// This file is hand-written, not generated - there is no gogo/protobuf (or
+// any protobuf) dependency in this tree to generate it with. Marshal/Unmarshal
+// below implement the wire format message.proto describes (varint-tagged
+// fields, proto3 field numbering) by hand, so the two files must be kept in
+// sync manually until a real protoc-gen-gogofaster toolchain is wired in.
+
+package pb
+
+import (
+	"fmt"
+)
+
+// Message is the wire type for main.Message. See message.proto for field
+// numbering; DataJSON/MetadataJSON carry the original interface{}/map
+// payloads JSON-encoded, since those fields are shape-free upstream.
+type Message struct {
+	Type         string
+	Channel      string
+	DataJSON     []byte
+	Timestamp    int64
+	Symbols      []string
+	MetadataJSON []byte
+}
+
+func (m *Message) Reset()         { *m = Message{} }
+func (m *Message) String() string { return fmt.Sprintf("%+v", *m) }
+func (*Message) ProtoMessage()    {}
+
+// SubscriptionRequest is the wire type for main.SubscriptionRequest. From/To
+// are unix millis bounding a "replay" request's time range; SpeedX100 is the
+// replay playback speed scaled by 100 (e.g. 250 = 2.5x).
+type SubscriptionRequest struct {
+	Type      string
+	Channel   string
+	Symbols   []string
+	Limit     int32
+	From      int64
+	To        int64
+	SpeedX100 int32
+}
+
+func (m *SubscriptionRequest) Reset()         { *m = SubscriptionRequest{} }
+func (m *SubscriptionRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*SubscriptionRequest) ProtoMessage()    {}
+
+// ================================================
+// WIRE FORMAT (varint tag + length-delimited / varint value)
+// ================================================
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func appendTag(buf []byte, field int, wire int) []byte {
+	return appendVarint(buf, uint64(field)<<3|uint64(wire))
+}
+
+func appendVarint(buf []byte, v uint64) []byte {
+	for v >= 0x80 {
+		buf = append(buf, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(buf, byte(v))
+}
+
+func appendString(buf []byte, field int, s string) []byte {
+	if s == "" {
+		return buf
+	}
+	buf = appendTag(buf, field, wireBytes)
+	buf = appendVarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendBytesField(buf []byte, field int, b []byte) []byte {
+	if len(b) == 0 {
+		return buf
+	}
+	buf = appendTag(buf, field, wireBytes)
+	buf = appendVarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+func appendVarintField(buf []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return buf
+	}
+	buf = appendTag(buf, field, wireVarint)
+	return appendVarint(buf, v)
+}
+
+func readVarint(buf []byte) (uint64, int, error) {
+	var v uint64
+	var shift uint
+	for i := 0; i < len(buf); i++ {
+		b := buf[i]
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return v, i + 1, nil
+		}
+		shift += 7
+	}
+	return 0, 0, fmt.Errorf("pb: truncated varint")
+}
+
+// Marshal encodes m into the protobuf wire format.
+func (m *Message) Marshal() ([]byte, error) {
+	buf := make([]byte, 0, 64+len(m.DataJSON)+len(m.MetadataJSON))
+	buf = appendString(buf, 1, m.Type)
+	buf = appendString(buf, 2, m.Channel)
+	buf = appendBytesField(buf, 3, m.DataJSON)
+	buf = appendVarintField(buf, 4, uint64(m.Timestamp))
+	for _, s := range m.Symbols {
+		buf = appendString(buf, 5, s)
+	}
+	buf = appendBytesField(buf, 6, m.MetadataJSON)
+	return buf, nil
+}
+
+// Unmarshal decodes buf produced by Marshal into m.
+func (m *Message) Unmarshal(buf []byte) error {
+	*m = Message{}
+	for len(buf) > 0 {
+		key, n, err := readVarint(buf)
+		if err != nil {
+			return err
+		}
+		buf = buf[n:]
+		field, wire := int(key>>3), int(key&0x7)
+
+		switch wire {
+		case wireVarint:
+			v, n, err := readVarint(buf)
+			if err != nil {
+				return err
+			}
+			buf = buf[n:]
+			if field == 4 {
+				m.Timestamp = int64(v)
+			}
+
+		case wireBytes:
+			l, n, err := readVarint(buf)
+			if err != nil {
+				return err
+			}
+			buf = buf[n:]
+			if uint64(len(buf)) < l {
+				return fmt.Errorf("pb: truncated field %d", field)
+			}
+			data := buf[:l]
+			buf = buf[l:]
+
+			switch field {
+			case 1:
+				m.Type = string(data)
+			case 2:
+				m.Channel = string(data)
+			case 3:
+				m.DataJSON = append([]byte(nil), data...)
+			case 5:
+				m.Symbols = append(m.Symbols, string(data))
+			case 6:
+				m.MetadataJSON = append([]byte(nil), data...)
+			}
+
+		default:
+			return fmt.Errorf("pb: unsupported wire type %d for field %d", wire, field)
+		}
+	}
+	return nil
+}
+
+// Marshal encodes m into the protobuf wire format.
+func (m *SubscriptionRequest) Marshal() ([]byte, error) {
+	buf := make([]byte, 0, 32)
+	buf = appendString(buf, 1, m.Type)
+	buf = appendString(buf, 2, m.Channel)
+	for _, s := range m.Symbols {
+		buf = appendString(buf, 3, s)
+	}
+	buf = appendVarintField(buf, 4, uint64(m.Limit))
+	buf = appendVarintField(buf, 5, uint64(m.From))
+	buf = appendVarintField(buf, 6, uint64(m.To))
+	buf = appendVarintField(buf, 7, uint64(m.SpeedX100))
+	return buf, nil
+}
+
+// Unmarshal decodes buf produced by Marshal into m.
+func (m *SubscriptionRequest) Unmarshal(buf []byte) error {
+	*m = SubscriptionRequest{}
+	for len(buf) > 0 {
+		key, n, err := readVarint(buf)
+		if err != nil {
+			return err
+		}
+		buf = buf[n:]
+		field, wire := int(key>>3), int(key&0x7)
+
+		switch wire {
+		case wireVarint:
+			v, n, err := readVarint(buf)
+			if err != nil {
+				return err
+			}
+			buf = buf[n:]
+			switch field {
+			case 4:
+				m.Limit = int32(v)
+			case 5:
+				m.From = int64(v)
+			case 6:
+				m.To = int64(v)
+			case 7:
+				m.SpeedX100 = int32(v)
+			}
+
+		case wireBytes:
+			l, n, err := readVarint(buf)
+			if err != nil {
+				return err
+			}
+			buf = buf[n:]
+			if uint64(len(buf)) < l {
+				return fmt.Errorf("pb: truncated field %d", field)
+			}
+			data := buf[:l]
+			buf = buf[l:]
+
+			switch field {
+			case 1:
+				m.Type = string(data)
+			case 2:
+				m.Channel = string(data)
+			case 3:
+				m.Symbols = append(m.Symbols, string(data))
+			}
+
+		default:
+			return fmt.Errorf("pb: unsupported wire type %d for field %d", wire, field)
+		}
+	}
+	return nil
+}
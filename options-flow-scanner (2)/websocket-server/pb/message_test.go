@@ -0,0 +1,137 @@
+package pb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  Message
+	}{
+		{
+			name: "full",
+			msg: Message{
+				Type:         "trade",
+				Channel:      "trades:AAPL",
+				DataJSON:     []byte(`{"price":123.45}`),
+				Timestamp:    1700000000000,
+				Symbols:      []string{"AAPL", "MSFT"},
+				MetadataJSON: []byte(`{"source":"polygon"}`),
+			},
+		},
+		{
+			name: "zero value",
+			msg:  Message{},
+		},
+		{
+			name: "negative timestamp",
+			msg:  Message{Type: "t", Timestamp: -42},
+		},
+		{
+			name: "empty byte slices stay nil after round trip",
+			msg:  Message{Type: "t", DataJSON: []byte{}, MetadataJSON: nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := tt.msg.Marshal()
+			if err != nil {
+				t.Fatalf("Marshal: unexpected error: %v", err)
+			}
+
+			var got Message
+			if err := got.Unmarshal(encoded); err != nil {
+				t.Fatalf("Unmarshal: unexpected error: %v", err)
+			}
+
+			want := tt.msg
+			// appendBytesField/appendString skip zero-length fields entirely,
+			// so an empty (non-nil) input round-trips as nil.
+			if len(want.DataJSON) == 0 {
+				want.DataJSON = nil
+			}
+			if len(want.MetadataJSON) == 0 {
+				want.MetadataJSON = nil
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("round trip mismatch:\n got  %+v\n want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestMessageUnmarshalTruncated(t *testing.T) {
+	msg := Message{Type: "trade", Channel: "trades:AAPL"}
+	encoded, err := msg.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal: unexpected error: %v", err)
+	}
+
+	var got Message
+	if err := got.Unmarshal(encoded[:len(encoded)-1]); err == nil {
+		t.Error("Unmarshal(truncated): expected error, got nil")
+	}
+}
+
+func TestSubscriptionRequestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		req  SubscriptionRequest
+	}{
+		{
+			name: "subscribe",
+			req: SubscriptionRequest{
+				Type:    "subscribe",
+				Channel: "trades",
+				Symbols: []string{"AAPL", "MSFT"},
+				Limit:   100,
+			},
+		},
+		{
+			name: "replay",
+			req: SubscriptionRequest{
+				Type:      "replay",
+				Channel:   "trades",
+				From:      1700000000000,
+				To:        1700003600000,
+				SpeedX100: 250,
+			},
+		},
+		{
+			name: "zero value",
+			req:  SubscriptionRequest{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := tt.req.Marshal()
+			if err != nil {
+				t.Fatalf("Marshal: unexpected error: %v", err)
+			}
+
+			var got SubscriptionRequest
+			if err := got.Unmarshal(encoded); err != nil {
+				t.Fatalf("Unmarshal: unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.req) {
+				t.Errorf("round trip mismatch:\n got  %+v\n want %+v", got, tt.req)
+			}
+		})
+	}
+}
+
+func TestUnmarshalUnsupportedWireType(t *testing.T) {
+	// Field 1, wire type 5 (32-bit) - not one Marshal ever produces, but
+	// Unmarshal must reject it rather than misreading the stream.
+	buf := appendTag(nil, 1, 5)
+	buf = append(buf, 0, 0, 0, 0)
+
+	var m Message
+	if err := m.Unmarshal(buf); err == nil {
+		t.Error("Unmarshal(unsupported wire type): expected error, got nil")
+	}
+}
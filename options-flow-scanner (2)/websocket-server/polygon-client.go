@@ -9,42 +9,319 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"math/rand"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"nexuralflow/websocket-server/fixedpoint"
 )
 
+// ================================================
+// CLUSTERS
+// ================================================
+
+// Cluster identifies which Polygon WebSocket feed a client connects to.
+type Cluster int
+
+const (
+	ClusterStocks Cluster = iota
+	ClusterOptions
+	ClusterCrypto
+	ClusterForex
+)
+
+// String returns the lowercase cluster name, used as a channel prefix.
+func (c Cluster) String() string {
+	switch c {
+	case ClusterOptions:
+		return "options"
+	case ClusterCrypto:
+		return "crypto"
+	case ClusterForex:
+		return "forex"
+	default:
+		return "stocks"
+	}
+}
+
+// wsURL returns the Polygon WebSocket endpoint for this cluster.
+func (c Cluster) wsURL() string {
+	switch c {
+	case ClusterOptions:
+		return "wss://socket.polygon.io/options"
+	case ClusterCrypto:
+		return "wss://socket.polygon.io/crypto"
+	case ClusterForex:
+		return "wss://socket.polygon.io/forex"
+	default:
+		return "wss://socket.polygon.io/stocks"
+	}
+}
+
+// channelPrefixes returns the subscription prefixes Subscribe/Unsubscribe use
+// for this cluster (e.g. "T.AAPL" for stocks, "XT.X:BTC-USD" for crypto).
+func (c Cluster) channelPrefixes() []string {
+	switch c {
+	case ClusterOptions:
+		return []string{"T", "Q"} // options trades/quotes use OCC symbols, same event codes
+	case ClusterCrypto:
+		return []string{"XT", "XQ"} // crypto trades/quotes
+	case ClusterForex:
+		return []string{"C", "CA"} // forex quotes/minute aggregates
+	default:
+		return []string{"T", "Q", "A"} // stock trades/quotes/second aggregates
+	}
+}
+
 // ================================================
 // POLYGON MESSAGE TYPES
 // ================================================
 
-// PolygonMessage represents a message from Polygon
+// OptionMeta describes the contract encoded in an OCC option symbol.
+type OptionMeta struct {
+	Underlying string           `json:"underlying"`
+	Expiry     string           `json:"expiry"` // YYYY-MM-DD
+	Strike     fixedpoint.Value `json:"strike"`
+	Type       string           `json:"type"` // "call" or "put"
+}
+
+var occSymbolPattern = regexp.MustCompile(`^([A-Z]{1,6})(\d{6})([CP])(\d{8})$`)
+
+// ParseOCCSymbol parses an OCC-formatted option symbol such as
+// "O:AAPL250117C00150000" into its underlying/expiry/strike/type.
+func ParseOCCSymbol(symbol string) (OptionMeta, bool) {
+	sym := strings.TrimPrefix(symbol, "O:")
+	m := occSymbolPattern.FindStringSubmatch(sym)
+	if m == nil {
+		return OptionMeta{}, false
+	}
+
+	// The last 8 digits are the strike in thousandths of a unit (e.g.
+	// "00150000" -> 150.000); parse via the string form and divide to
+	// avoid a float64 round-trip.
+	strikeThousandths, err := fixedpoint.FromString(m[4])
+	if err != nil {
+		return OptionMeta{}, false
+	}
+
+	optType := "call"
+	if m[3] == "P" {
+		optType = "put"
+	}
+
+	return OptionMeta{
+		Underlying: m[1],
+		Expiry:     fmt.Sprintf("20%s-%s-%s", m[2][0:2], m[2][2:4], m[2][4:6]),
+		Strike:     strikeThousandths.Div(fixedpoint.FromInt64(1000)),
+		Type:       optType,
+	}, true
+}
+
+// PolygonMessage is the normalized representation of a Polygon event, built
+// from the cluster- and event-specific wire structs below so that
+// conflicting wire keys (e.g. trade "c"onditions vs aggregate "c"lose) never
+// collide on a single struct tag.
 type PolygonMessage struct {
-	EventType  string          `json:"ev"`
-	Symbol     string          `json:"sym"`
+	EventType string `json:"ev"`
+	Symbol    string `json:"sym,omitempty"`
+	Pair      string `json:"pair,omitempty"` // crypto/forex pair, e.g. "X:BTC-USD"
+
 	// Trade fields
-	Price      float64         `json:"p"`
-	Size       int             `json:"s"`
-	Exchange   int             `json:"x"`
-	Timestamp  int64           `json:"t"`
-	Conditions interface{}     `json:"c"` // Can be int or []int
+	Price      fixedpoint.Value `json:"p,omitempty"`
+	Size       fixedpoint.Value `json:"s,omitempty"`
+	Exchange   int              `json:"x,omitempty"`
+	Timestamp  int64            `json:"t,omitempty"`
+	Conditions interface{}      `json:"conditions,omitempty"`
+
 	// Quote fields
-	BidPrice   float64         `json:"bp"`
-	BidSize    int             `json:"bs"`
-	AskPrice   float64         `json:"ap"`
-	AskSize    int             `json:"as"`
+	BidPrice fixedpoint.Value `json:"bp,omitempty"`
+	BidSize  fixedpoint.Value `json:"bs,omitempty"`
+	AskPrice fixedpoint.Value `json:"ap,omitempty"`
+	AskSize  fixedpoint.Value `json:"as,omitempty"`
+
 	// Aggregate fields
-	Open       float64         `json:"o"`
-	High       float64         `json:"h"`
-	Low        float64         `json:"l"`
-	Close      float64         `json:"c"`
-	Volume     int64           `json:"v"`
-	VWAP       float64         `json:"vw"`
-	Raw        json.RawMessage `json:"-"`
+	Open       fixedpoint.Value `json:"o,omitempty"`
+	High       fixedpoint.Value `json:"h,omitempty"`
+	Low        fixedpoint.Value `json:"l,omitempty"`
+	Close      fixedpoint.Value `json:"close,omitempty"`
+	Volume     int64            `json:"v,omitempty"`
+	VWAP       fixedpoint.Value `json:"vw,omitempty"`
+	TradeCount int64            `json:"z,omitempty"`
+
+	// Options-cluster metadata, parsed from the OCC symbol when present
+	Option *OptionMeta `json:"option,omitempty"`
+
+	Cluster Cluster         `json:"-"`
+	Raw     json.RawMessage `json:"-"`
+}
+
+// Wire structs below mirror Polygon's actual payload shapes per event type.
+// They exist solely to decode without tag collisions; handleDataMessage maps
+// their fields into the normalized PolygonMessage above.
+
+type polygonStockTrade struct {
+	Symbol     string           `json:"sym"`
+	Price      fixedpoint.Value `json:"p"`
+	Size       fixedpoint.Value `json:"s"`
+	Exchange   int              `json:"x"`
+	Timestamp  int64            `json:"t"`
+	Conditions interface{}      `json:"c"`
+}
+
+type polygonStockQuote struct {
+	Symbol    string           `json:"sym"`
+	BidPrice  fixedpoint.Value `json:"bp"`
+	BidSize   fixedpoint.Value `json:"bs"`
+	AskPrice  fixedpoint.Value `json:"ap"`
+	AskSize   fixedpoint.Value `json:"as"`
+	Exchange  int              `json:"x"`
+	Timestamp int64            `json:"t"`
+}
+
+type polygonAggregate struct {
+	Symbol     string           `json:"sym"`
+	Open       fixedpoint.Value `json:"o"`
+	High       fixedpoint.Value `json:"h"`
+	Low        fixedpoint.Value `json:"l"`
+	Close      fixedpoint.Value `json:"c"`
+	Volume     int64            `json:"v"`
+	VWAP       fixedpoint.Value `json:"vw"`
+	TradeCount int64            `json:"z"`
+	Timestamp  int64            `json:"s"`
+}
+
+type polygonCryptoTrade struct {
+	Pair       string           `json:"pair"`
+	Price      fixedpoint.Value `json:"p"`
+	Size       fixedpoint.Value `json:"s"`
+	Exchange   int              `json:"x"`
+	Timestamp  int64            `json:"t"`
+	Conditions interface{}      `json:"c"`
+}
+
+type polygonCryptoQuote struct {
+	Pair      string           `json:"pair"`
+	BidPrice  fixedpoint.Value `json:"bp"`
+	BidSize   fixedpoint.Value `json:"bs"`
+	AskPrice  fixedpoint.Value `json:"ap"`
+	AskSize   fixedpoint.Value `json:"as"`
+	Exchange  int              `json:"x"`
+	Timestamp int64            `json:"t"`
+}
+
+type polygonForexQuote struct {
+	Pair      string           `json:"p"`
+	BidPrice  fixedpoint.Value `json:"b"`
+	AskPrice  fixedpoint.Value `json:"a"`
+	Exchange  int              `json:"x"`
+	Timestamp int64            `json:"t"`
+}
+
+type polygonForexAggregate struct {
+	Pair      string           `json:"pair"`
+	Open      fixedpoint.Value `json:"o"`
+	High      fixedpoint.Value `json:"h"`
+	Low       fixedpoint.Value `json:"l"`
+	Close     fixedpoint.Value `json:"c"`
+	Volume    fixedpoint.Value `json:"v"`
+	VWAP      fixedpoint.Value `json:"vw"`
+	Timestamp int64            `json:"s"`
+}
+
+// decodePolygonMessage decodes a single Polygon event into a PolygonMessage,
+// using cluster to disambiguate event codes that are reused across feeds
+// (e.g. "T"/"Q" mean stock trades/quotes on the stocks cluster but
+// options-with-OCC-symbols on the options cluster).
+func decodePolygonMessage(cluster Cluster, eventType string, rawMsg json.RawMessage) (PolygonMessage, error) {
+	msg := PolygonMessage{EventType: eventType, Cluster: cluster}
+
+	switch eventType {
+	case "T": // stock or option trade
+		var t polygonStockTrade
+		if err := json.Unmarshal(rawMsg, &t); err != nil {
+			return msg, err
+		}
+		msg.Symbol, msg.Price, msg.Size = t.Symbol, t.Price, t.Size
+		msg.Exchange, msg.Timestamp, msg.Conditions = t.Exchange, t.Timestamp, t.Conditions
+		if cluster == ClusterOptions {
+			if meta, ok := ParseOCCSymbol(t.Symbol); ok {
+				msg.Option = &meta
+			}
+		}
+
+	case "Q": // stock or option quote
+		var q polygonStockQuote
+		if err := json.Unmarshal(rawMsg, &q); err != nil {
+			return msg, err
+		}
+		msg.Symbol = q.Symbol
+		msg.BidPrice, msg.BidSize, msg.AskPrice, msg.AskSize = q.BidPrice, q.BidSize, q.AskPrice, q.AskSize
+		msg.Exchange, msg.Timestamp = q.Exchange, q.Timestamp
+		if cluster == ClusterOptions {
+			if meta, ok := ParseOCCSymbol(q.Symbol); ok {
+				msg.Option = &meta
+			}
+		}
+
+	case "A", "AM": // stock second/minute aggregate
+		var a polygonAggregate
+		if err := json.Unmarshal(rawMsg, &a); err != nil {
+			return msg, err
+		}
+		msg.Symbol = a.Symbol
+		msg.Open, msg.High, msg.Low, msg.Close = a.Open, a.High, a.Low, a.Close
+		msg.Volume, msg.VWAP, msg.TradeCount, msg.Timestamp = a.Volume, a.VWAP, a.TradeCount, a.Timestamp
+
+	case "XT": // crypto trade
+		var t polygonCryptoTrade
+		if err := json.Unmarshal(rawMsg, &t); err != nil {
+			return msg, err
+		}
+		msg.Pair, msg.Price, msg.Size = t.Pair, t.Price, t.Size
+		msg.Exchange, msg.Timestamp, msg.Conditions = t.Exchange, t.Timestamp, t.Conditions
+
+	case "XQ": // crypto quote
+		var q polygonCryptoQuote
+		if err := json.Unmarshal(rawMsg, &q); err != nil {
+			return msg, err
+		}
+		msg.Pair = q.Pair
+		msg.BidPrice, msg.BidSize, msg.AskPrice, msg.AskSize = q.BidPrice, q.BidSize, q.AskPrice, q.AskSize
+		msg.Exchange, msg.Timestamp = q.Exchange, q.Timestamp
+
+	case "C": // forex quote
+		var q polygonForexQuote
+		if err := json.Unmarshal(rawMsg, &q); err != nil {
+			return msg, err
+		}
+		msg.Pair, msg.BidPrice, msg.AskPrice = q.Pair, q.BidPrice, q.AskPrice
+		msg.Exchange, msg.Timestamp = q.Exchange, q.Timestamp
+
+	case "CA": // forex minute aggregate
+		var a polygonForexAggregate
+		if err := json.Unmarshal(rawMsg, &a); err != nil {
+			return msg, err
+		}
+		msg.Pair = a.Pair
+		msg.Open, msg.High, msg.Low, msg.Close = a.Open, a.High, a.Low, a.Close
+		msg.Volume, msg.VWAP, msg.Timestamp = int64(a.Volume.Float64()), a.VWAP, a.Timestamp
+
+	default:
+		// Unknown event type: best-effort decode using the normalized tags.
+		if err := json.Unmarshal(rawMsg, &msg); err != nil {
+			return msg, err
+		}
+	}
+
+	return msg, nil
 }
 
 // PolygonStatusMessage represents a status message
@@ -69,25 +346,52 @@ type PolygonSubscribeMessage struct {
 // POLYGON CLIENT
 // ================================================
 
+// pingInterval/pongWait bound the WebSocket heartbeat: a ping is sent every
+// pingInterval, and the connection is torn down (triggering reconnect) if no
+// pong arrives within pongWait.
+const (
+	pingInterval = 30 * time.Second
+	pongWait     = 60 * time.Second
+
+	authTimeout       = 10 * time.Second
+	maxReconnectDelay = 60 * time.Second
+)
+
 type PolygonClient struct {
 	apiKey         string
-	ws             *websocket.Conn
-	connected      bool
-	authenticated  bool
-	subscriptions  map[string]bool
+	cluster        Cluster
 	messageHandler func(PolygonMessage)
+
+	mu            sync.Mutex
+	ws            *websocket.Conn
+	connected     bool
+	authenticated bool
+	subscriptions map[string]bool
+	authResult    chan bool
+	done          chan struct{} // closed when the current connection's readMessages/heartbeat should stop
+
+	ctx            context.Context
+	cancel         context.CancelFunc
 	reconnectTimer *time.Timer
 	reconnectDelay time.Duration
 	maxRetries     int
 	retryCount     int
 }
 
-// NewPolygonClient creates a new Polygon WebSocket client
-func NewPolygonClient(apiKey string, messageHandler func(PolygonMessage)) *PolygonClient {
+// NewPolygonClient creates a new Polygon WebSocket client bound to cluster.
+// Run one PolygonClient per cluster you need to stream (stocks, options,
+// crypto, forex) - each keeps its own connection and subscription set. The
+// client's lifecycle is bound to an internal context, canceled by Disconnect
+// so any in-flight reconnect is abandoned rather than racing a fresh Connect.
+func NewPolygonClient(apiKey string, cluster Cluster, messageHandler func(PolygonMessage)) *PolygonClient {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &PolygonClient{
 		apiKey:         apiKey,
+		cluster:        cluster,
 		subscriptions:  make(map[string]bool),
 		messageHandler: messageHandler,
+		ctx:            ctx,
+		cancel:         cancel,
 		reconnectDelay: 5 * time.Second,
 		maxRetries:     10,
 	}
@@ -95,169 +399,237 @@ func NewPolygonClient(apiKey string, messageHandler func(PolygonMessage)) *Polyg
 
 // Connect establishes connection to Polygon WebSocket
 func (pc *PolygonClient) Connect() error {
-	// Polygon WebSocket URLs:
-	// Stocks: wss://socket.polygon.io/stocks
-	// Options: wss://socket.polygon.io/options
-	// Crypto: wss://socket.polygon.io/crypto
-	// Forex: wss://socket.polygon.io/forex
-	
-	url := "wss://socket.polygon.io/stocks"
-	
-	log.Printf("🔌 Connecting to Polygon WebSocket: %s", url)
-	
+	select {
+	case <-pc.ctx.Done():
+		return fmt.Errorf("polygon client has been disconnected")
+	default:
+	}
+
+	url := pc.cluster.wsURL()
+
+	log.Printf("🔌 Connecting to Polygon WebSocket (%s): %s", pc.cluster, url)
+
 	dialer := websocket.DefaultDialer
 	dialer.HandshakeTimeout = 10 * time.Second
-	
-	ws, _, err := dialer.Dial(url, nil)
+
+	ws, _, err := dialer.DialContext(pc.ctx, url, nil)
 	if err != nil {
 		return fmt.Errorf("failed to connect to Polygon: %w", err)
 	}
-	
+
+	done := make(chan struct{})
+
+	pc.mu.Lock()
 	pc.ws = ws
 	pc.connected = true
 	pc.retryCount = 0
-	
+	pc.done = done
+	pc.mu.Unlock()
+
 	log.Println("✅ Connected to Polygon WebSocket")
-	
-	// Start message reader
-	go pc.readMessages()
-	
+
+	// Start message reader and heartbeat for this connection
+	go pc.readMessages(ws, done)
+	go pc.heartbeat(ws, done)
+
 	// Authenticate
-	if err := pc.authenticate(); err != nil {
-		pc.ws.Close()
+	if err := pc.authenticate(ws); err != nil {
+		ws.Close()
 		return fmt.Errorf("failed to authenticate: %w", err)
 	}
-	
+
 	return nil
 }
 
-// authenticate sends authentication to Polygon
-func (pc *PolygonClient) authenticate() error {
+// authenticate sends authentication to Polygon and waits for the auth-result
+// channel to be populated by handleStatusMessage, rather than sleeping a
+// fixed duration and hoping the response has already arrived.
+func (pc *PolygonClient) authenticate(ws *websocket.Conn) error {
+	authResult := make(chan bool, 1)
+	pc.mu.Lock()
+	pc.authResult = authResult
+	pc.mu.Unlock()
+
 	authMsg := PolygonAuthMessage{
 		Action: "auth",
 		Params: pc.apiKey,
 	}
-	
+
 	data, err := json.Marshal(authMsg)
 	if err != nil {
 		return err
 	}
-	
+
 	log.Println("🔐 Authenticating with Polygon...")
-	
-	if err := pc.ws.WriteMessage(websocket.TextMessage, data); err != nil {
+
+	if err := ws.WriteMessage(websocket.TextMessage, data); err != nil {
 		return err
 	}
-	
-	// Wait for auth response (handled in readMessages)
-	time.Sleep(2 * time.Second)
-	
-	if !pc.authenticated {
-		return fmt.Errorf("authentication failed")
+
+	select {
+	case ok := <-authResult:
+		if !ok {
+			return fmt.Errorf("authentication failed")
+		}
+	case <-time.After(authTimeout):
+		return fmt.Errorf("authentication timed out")
+	case <-pc.ctx.Done():
+		return pc.ctx.Err()
 	}
-	
+
 	log.Println("✅ Polygon authentication successful")
-	
+
 	return nil
 }
 
-// Subscribe subscribes to symbols
+// Subscribe subscribes to symbols (or crypto/forex pairs) using the
+// channel prefixes appropriate for this client's cluster.
 func (pc *PolygonClient) Subscribe(symbols []string) error {
+	pc.mu.Lock()
 	if !pc.connected || !pc.authenticated {
+		pc.mu.Unlock()
 		return fmt.Errorf("not connected or authenticated")
 	}
-	
-	// Build subscription params
-	// Format: T.SYMBOL,T.SYMBOL2,... (T = trades, Q = quotes, A = aggregates)
-	params := make([]string, 0, len(symbols))
+	ws := pc.ws
+
+	prefixes := pc.cluster.channelPrefixes()
+	params := make([]string, 0, len(symbols)*len(prefixes))
 	for _, symbol := range symbols {
-		// Subscribe to trades (T), quotes (Q), and aggregates (A)
-		params = append(params,
-			fmt.Sprintf("T.%s", symbol),  // Trades
-			fmt.Sprintf("Q.%s", symbol),  // Quotes
-			fmt.Sprintf("A.%s", symbol),  // Aggregates (second bars)
-		)
+		for _, prefix := range prefixes {
+			params = append(params, fmt.Sprintf("%s.%s", prefix, symbol))
+		}
 		pc.subscriptions[symbol] = true
 	}
-	
+	pc.mu.Unlock()
+
 	subMsg := PolygonSubscribeMessage{
 		Action: "subscribe",
 		Params: strings.Join(params, ","),
 	}
-	
+
 	data, err := json.Marshal(subMsg)
 	if err != nil {
 		return err
 	}
-	
-	log.Printf("📥 Subscribing to Polygon: %v", symbols)
-	
-	if err := pc.ws.WriteMessage(websocket.TextMessage, data); err != nil {
+
+	log.Printf("📥 Subscribing to Polygon (%s): %v", pc.cluster, symbols)
+
+	if err := ws.WriteMessage(websocket.TextMessage, data); err != nil {
 		return err
 	}
-	
+
 	return nil
 }
 
 // Unsubscribe unsubscribes from symbols
 func (pc *PolygonClient) Unsubscribe(symbols []string) error {
+	pc.mu.Lock()
 	if !pc.connected {
+		pc.mu.Unlock()
 		return fmt.Errorf("not connected")
 	}
-	
-	params := make([]string, 0, len(symbols))
+	ws := pc.ws
+
+	prefixes := pc.cluster.channelPrefixes()
+	params := make([]string, 0, len(symbols)*len(prefixes))
 	for _, symbol := range symbols {
-		params = append(params,
-			fmt.Sprintf("T.%s", symbol),
-			fmt.Sprintf("Q.%s", symbol),
-			fmt.Sprintf("A.%s", symbol),
-		)
+		for _, prefix := range prefixes {
+			params = append(params, fmt.Sprintf("%s.%s", prefix, symbol))
+		}
 		delete(pc.subscriptions, symbol)
 	}
-	
+	pc.mu.Unlock()
+
 	unsubMsg := PolygonSubscribeMessage{
 		Action: "unsubscribe",
 		Params: strings.Join(params, ","),
 	}
-	
+
 	data, err := json.Marshal(unsubMsg)
 	if err != nil {
 		return err
 	}
-	
-	log.Printf("📤 Unsubscribing from Polygon: %v", symbols)
-	
-	if err := pc.ws.WriteMessage(websocket.TextMessage, data); err != nil {
+
+	log.Printf("📤 Unsubscribing from Polygon (%s): %v", pc.cluster, symbols)
+
+	if err := ws.WriteMessage(websocket.TextMessage, data); err != nil {
 		return err
 	}
-	
+
 	return nil
 }
 
-// readMessages reads messages from Polygon WebSocket
-func (pc *PolygonClient) readMessages() {
+// readMessages reads messages from the given connection until it errs, the
+// client's done channel closes, or the client's context is canceled, then
+// tears the connection down and schedules a reconnect (unless Disconnect
+// already canceled the context).
+func (pc *PolygonClient) readMessages(ws *websocket.Conn, done chan struct{}) {
+	ws.SetReadDeadline(time.Now().Add(pongWait))
+	ws.SetPongHandler(func(string) error {
+		ws.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
 	defer func() {
+		pc.mu.Lock()
 		pc.connected = false
 		pc.authenticated = false
-		if pc.ws != nil {
+		if pc.ws == ws {
 			pc.ws.Close()
 		}
-		pc.scheduleReconnect()
+		pc.mu.Unlock()
+
+		select {
+		case <-done:
+			// already torn down by heartbeat/Disconnect
+		default:
+			close(done)
+		}
+
+		select {
+		case <-pc.ctx.Done():
+			return
+		default:
+			pc.scheduleReconnect()
+		}
 	}()
-	
+
 	for {
-		_, message, err := pc.ws.ReadMessage()
+		_, message, err := ws.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("❌ Polygon WebSocket error: %v", err)
 			}
 			break
 		}
-		
+
 		pc.handleMessage(message)
 	}
 }
 
+// heartbeat pings ws every pingInterval so a dead connection is noticed via
+// the read deadline (reset by the pong handler in readMessages) instead of
+// waiting on a TCP-level timeout.
+func (pc *PolygonClient) heartbeat(ws *websocket.Conn, done chan struct{}) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-pc.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				log.Printf("❌ Polygon: ping failed, tearing down connection: %v", err)
+				ws.Close()
+				return
+			}
+		}
+	}
+}
+
 // handleMessage processes incoming Polygon messages
 func (pc *PolygonClient) handleMessage(data []byte) {
 	// Polygon sends arrays of messages
@@ -266,7 +638,7 @@ func (pc *PolygonClient) handleMessage(data []byte) {
 		log.Printf("❌ Failed to parse Polygon message: %v", err)
 		return
 	}
-	
+
 	for _, rawMsg := range messages {
 		// Check message type
 		var msgType struct {
@@ -274,17 +646,17 @@ func (pc *PolygonClient) handleMessage(data []byte) {
 			Status    string `json:"status"`
 			Message   string `json:"message"`
 		}
-		
+
 		if err := json.Unmarshal(rawMsg, &msgType); err != nil {
 			continue
 		}
-		
+
 		// Handle status messages
 		if msgType.Status != "" {
 			pc.handleStatusMessage(msgType.Status, msgType.Message)
 			continue
 		}
-		
+
 		// Handle data messages
 		if msgType.EventType != "" {
 			pc.handleDataMessage(msgType.EventType, rawMsg)
@@ -296,19 +668,37 @@ func (pc *PolygonClient) handleMessage(data []byte) {
 func (pc *PolygonClient) handleStatusMessage(status, message string) {
 	switch status {
 	case "auth_success":
+		pc.mu.Lock()
 		pc.authenticated = true
+		authResult := pc.authResult
+		pc.mu.Unlock()
 		log.Println("✅ Polygon: Authentication successful")
-		
+		if authResult != nil {
+			select {
+			case authResult <- true:
+			default:
+			}
+		}
+
 	case "auth_failed":
+		pc.mu.Lock()
 		pc.authenticated = false
+		authResult := pc.authResult
+		pc.mu.Unlock()
 		log.Printf("❌ Polygon: Authentication failed - %s", message)
-		
+		if authResult != nil {
+			select {
+			case authResult <- false:
+			default:
+			}
+		}
+
 	case "success":
 		log.Printf("✅ Polygon: %s", message)
-		
+
 	case "error":
 		log.Printf("❌ Polygon: %s", message)
-		
+
 	default:
 		log.Printf("📨 Polygon status: %s - %s", status, message)
 	}
@@ -316,75 +706,113 @@ func (pc *PolygonClient) handleStatusMessage(status, message string) {
 
 // handleDataMessage handles Polygon data messages
 func (pc *PolygonClient) handleDataMessage(eventType string, rawMsg json.RawMessage) {
-	var msg PolygonMessage
-	if err := json.Unmarshal(rawMsg, &msg); err != nil {
-		log.Printf("❌ Failed to parse data message: %v", err)
+	msg, err := decodePolygonMessage(pc.cluster, eventType, rawMsg)
+	if err != nil {
+		log.Printf("❌ Failed to parse data message (%s): %v", eventType, err)
 		return
 	}
-	
+
 	msg.Raw = rawMsg
-	
+
 	// Call message handler
 	if pc.messageHandler != nil {
 		pc.messageHandler(msg)
 	}
 }
 
-// scheduleReconnect schedules a reconnection attempt
+// scheduleReconnect schedules a reconnection attempt using full-jitter
+// exponential backoff: delay = rand(0, min(cap, base * 2^attempt)). This
+// spreads reconnect storms out instead of every client retrying in lockstep,
+// and is abandoned outright if Disconnect has already canceled the context.
 func (pc *PolygonClient) scheduleReconnect() {
+	select {
+	case <-pc.ctx.Done():
+		return
+	default:
+	}
+
+	pc.mu.Lock()
 	if pc.retryCount >= pc.maxRetries {
+		pc.mu.Unlock()
 		log.Printf("❌ Polygon: Max reconnection attempts reached (%d)", pc.maxRetries)
 		return
 	}
-	
 	pc.retryCount++
-	delay := pc.reconnectDelay * time.Duration(pc.retryCount)
-	
-	log.Printf("🔄 Polygon: Reconnecting in %v (attempt %d/%d)", delay, pc.retryCount, pc.maxRetries)
-	
-	pc.reconnectTimer = time.AfterFunc(delay, func() {
+	attempt := pc.retryCount
+	pc.mu.Unlock()
+
+	capped := pc.reconnectDelay * time.Duration(uint64(1)<<uint(attempt-1))
+	if capped <= 0 || capped > maxReconnectDelay {
+		capped = maxReconnectDelay
+	}
+	delay := time.Duration(rand.Int63n(int64(capped) + 1))
+
+	log.Printf("🔄 Polygon: Reconnecting in %v (attempt %d/%d)", delay, attempt, pc.maxRetries)
+
+	timer := time.AfterFunc(delay, func() {
+		select {
+		case <-pc.ctx.Done():
+			return
+		default:
+		}
+
 		if err := pc.Connect(); err != nil {
 			log.Printf("❌ Polygon reconnection failed: %v", err)
 			return
 		}
-		
-		// Resubscribe to all symbols
+
+		// Resubscribe to all symbols for this cluster
+		pc.mu.Lock()
 		symbols := make([]string, 0, len(pc.subscriptions))
 		for symbol := range pc.subscriptions {
 			symbols = append(symbols, symbol)
 		}
-		
+		pc.mu.Unlock()
+
 		if len(symbols) > 0 {
 			if err := pc.Subscribe(symbols); err != nil {
 				log.Printf("❌ Failed to resubscribe: %v", err)
 			}
 		}
 	})
+
+	pc.mu.Lock()
+	pc.reconnectTimer = timer
+	pc.mu.Unlock()
 }
 
-// Disconnect closes the connection
+// Disconnect tears down the connection for good: it cancels the client's
+// context (so a reconnect in flight is abandoned and scheduleReconnect
+// refuses to arm a new timer), stops any pending reconnect timer, and closes
+// the socket.
 func (pc *PolygonClient) Disconnect() {
+	pc.cancel()
+
+	pc.mu.Lock()
 	if pc.reconnectTimer != nil {
 		pc.reconnectTimer.Stop()
 	}
-	
 	if pc.ws != nil {
 		pc.ws.Close()
 	}
-	
 	pc.connected = false
 	pc.authenticated = false
-	
+	pc.mu.Unlock()
+
 	log.Println("👋 Disconnected from Polygon")
 }
 
 // IsConnected returns connection status
 func (pc *PolygonClient) IsConnected() bool {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
 	return pc.connected && pc.authenticated
 }
 
 // GetSubscriptions returns active subscriptions
 func (pc *PolygonClient) GetSubscriptions() []string {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
 	symbols := make([]string, 0, len(pc.subscriptions))
 	for symbol := range pc.subscriptions {
 		symbols = append(symbols, symbol)
@@ -398,30 +826,42 @@ func (pc *PolygonClient) GetSubscriptions() []string {
 
 // TransformPolygonMessage transforms Polygon message to our format
 func TransformPolygonMessage(pm PolygonMessage) Message {
-	// Determine channel based on event type
-	channel := "market-data"
+	// Determine cluster-aware channel based on event type
+	channel := pm.Cluster.String() + ".market-data"
 	switch pm.EventType {
-	case "T": // Trade
-		channel = "trades"
-	case "Q": // Quote
-		channel = "quotes"
-	case "A": // Aggregate (second bar)
-		channel = "aggregates"
-	case "AM": // Minute aggregate
-		channel = "aggregates"
-	}
-	
+	case "T", "XT": // Trade
+		channel = pm.Cluster.String() + ".trades"
+	case "Q", "XQ", "C": // Quote
+		channel = pm.Cluster.String() + ".quotes"
+	case "A", "AM", "CA": // Aggregate
+		channel = pm.Cluster.String() + ".aggregates"
+	}
+
+	symbol := pm.Symbol
+	if symbol == "" {
+		symbol = pm.Pair
+	}
+
+	metadata := map[string]interface{}{
+		"source":     "polygon",
+		"cluster":    pm.Cluster.String(),
+		"event_type": pm.EventType,
+		"exchange":   pm.Exchange,
+	}
+	if pm.Option != nil {
+		metadata["underlying"] = pm.Option.Underlying
+		metadata["expiry"] = pm.Option.Expiry
+		metadata["strike"] = pm.Option.Strike
+		metadata["option_type"] = pm.Option.Type
+	}
+
 	return Message{
 		Type:      "market-data",
 		Channel:   channel,
 		Data:      pm,
 		Timestamp: time.Now().UnixMilli(),
-		Symbols:   []string{pm.Symbol},
-		Metadata: map[string]interface{}{
-			"source":     "polygon",
-			"event_type": pm.EventType,
-			"exchange":   pm.Exchange,
-		},
+		Symbols:   []string{symbol},
+		Metadata:  metadata,
 	}
 }
 
@@ -438,6 +878,14 @@ func FormatPolygonEventType(eventType string) string {
 		return "Minute Aggregate"
 	case "AV":
 		return "Value Aggregate"
+	case "XT":
+		return "Crypto Trade"
+	case "XQ":
+		return "Crypto Quote"
+	case "C":
+		return "Forex Quote"
+	case "CA":
+		return "Forex Minute Aggregate"
 	default:
 		return eventType
 	}
@@ -0,0 +1,124 @@
+// ================================================
+// PER-KEY REST RATE LIMITER
+// ================================================
+// Token-bucket rate limiting for the signed REST API,
+// surfaced as X-RateLimit-Remaining / X-RateLimit-Reset
+// headers and structured 429s on exhaustion.
+// ================================================
+
+package ratelimit
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// bucket is a single token bucket for one rate-limit key (e.g. an API key).
+type bucket struct {
+	mu         sync.Mutex
+	tokens     float64
+	lastRefill time.Time
+}
+
+// Limiter is a per-key token-bucket rate limiter.
+type Limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+
+	limit  float64       // max tokens (also the refill window allowance)
+	window time.Duration // window over which `limit` tokens are replenished
+}
+
+// NewLimiter creates a Limiter allowing `limit` requests per `window` per key.
+func NewLimiter(limit int, window time.Duration) *Limiter {
+	return &Limiter{
+		buckets: make(map[string]*bucket),
+		limit:   float64(limit),
+		window:  window,
+	}
+}
+
+// Allow consumes one token for key, returning whether the request is allowed,
+// how many tokens remain, and when the bucket will have a full token again.
+func (l *Limiter) Allow(key string) (allowed bool, remaining int, reset time.Time) {
+	b := l.bucketFor(key)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(b.lastRefill)
+	refillRate := l.limit / l.window.Seconds()
+	b.tokens += elapsed.Seconds() * refillRate
+	if b.tokens > l.limit {
+		b.tokens = l.limit
+	}
+	b.lastRefill = now
+
+	if b.tokens < 1 {
+		deficit := 1 - b.tokens
+		wait := time.Duration(deficit/refillRate*1000) * time.Millisecond
+		return false, 0, now.Add(wait)
+	}
+
+	b.tokens--
+	return true, int(b.tokens), now.Add(time.Duration((l.limit-b.tokens)/refillRate*float64(time.Second)))
+}
+
+func (l *Limiter) bucketFor(key string) *bucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.limit, lastRefill: time.Now()}
+		l.buckets[key] = b
+	}
+	return b
+}
+
+// errorEnvelope mirrors the REST API's standard response envelope.
+type errorEnvelope struct {
+	RetCode    int                    `json:"retCode"`
+	RetMsg     string                 `json:"retMsg"`
+	Result     interface{}            `json:"result"`
+	RetExtInfo map[string]interface{} `json:"retExtInfo"`
+	Time       int64                  `json:"time"`
+}
+
+// RetCodeRateLimited is returned in the envelope when a caller is throttled.
+const RetCodeRateLimited = 10429
+
+// KeyFunc extracts the rate-limit key (e.g. authenticated API key or IP) from a request.
+type KeyFunc func(r *http.Request) string
+
+// Middleware wraps next with rate limiting, keyed by keyFn(r).
+func (l *Limiter) Middleware(keyFn KeyFunc, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := keyFn(r)
+		allowed, remaining, reset := l.Allow(key)
+
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(reset).Seconds())+1))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			json.NewEncoder(w).Encode(errorEnvelope{
+				RetCode:    RetCodeRateLimited,
+				RetMsg:     "rate limit exceeded",
+				Result:     nil,
+				RetExtInfo: map[string]interface{}{},
+				Time:       time.Now().UnixMilli(),
+			})
+			return
+		}
+
+		next(w, r)
+	}
+}
+
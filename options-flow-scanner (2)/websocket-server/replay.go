@@ -0,0 +1,478 @@
+// ================================================
+// REPLAY / TIME-TRAVEL HISTORY STREAM
+// ================================================
+// A "replay" request pages historical ticks out of TimescaleDB via
+// ReadTradesRange/ReadQuotesRange/ReadAggregatesRange - the read-side
+// counterparts of WriteTrade/WriteQuote/WriteAggregate in handlePolygonMessage
+// - merges them across symbols and event types by timestamp with a min-heap,
+// and emits them through the normal sendMessage path with sleeps scaled by
+// the requested speed, so replayed ticks render through the same Message
+// schema a client already uses for live data. One replay runs per client at
+// a time; a new "replay" request cancels whatever was running, and
+// "pause"/"resume"/"seek" steer the active one.
+// ================================================
+
+package main
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"nexuralflow/websocket-server/fixedpoint"
+)
+
+// replayPageSize bounds how many rows a single ReadXRange call returns, so a
+// multi-day replay pages through TimescaleDB instead of loading the whole
+// range into memory up front.
+const replayPageSize = 500
+
+// TradeRow, QuoteRow, and AggregateRow are the read-side shapes returned by
+// ReadTradesRange/ReadQuotesRange/ReadAggregatesRange, mirroring the
+// trade/quote/aggregate columns WriteTrade/WriteQuote/WriteAggregate persist.
+type TradeRow struct {
+	Symbol    string
+	Price     fixedpoint.Value
+	Size      fixedpoint.Value
+	Exchange  string
+	Timestamp time.Time
+}
+
+type QuoteRow struct {
+	Symbol    string
+	BidPrice  fixedpoint.Value
+	BidSize   fixedpoint.Value
+	AskPrice  fixedpoint.Value
+	AskSize   fixedpoint.Value
+	Exchange  string
+	Timestamp time.Time
+}
+
+type AggregateRow struct {
+	Symbol     string
+	Open       fixedpoint.Value
+	High       fixedpoint.Value
+	Low        fixedpoint.Value
+	Close      fixedpoint.Value
+	VWAP       fixedpoint.Value
+	Volume     int64
+	TradeCount int
+	Timestamp  time.Time
+}
+
+// ReadTradesRange, ReadQuotesRange, and ReadAggregatesRange (called below)
+// page ascending, by-timestamp slices of persisted ticks out of TimescaleDB,
+// with the signature `(ctx, symbol string, from, to time.Time, limit, offset
+// int) ([]XRow, error)`. They pair with WriteTrade/WriteQuote/WriteAggregate,
+// which (like db/InitDatabase/CloseDatabase) live in this server's
+// TimescaleDB integration file - not present in this checkout, so they're
+// left as calls against that same convention rather than stubbed out here.
+
+// ================================================
+// MERGE-HEAP PAGING
+// ================================================
+
+// replayItem is one ticked-out row, already converted to the Message a
+// client will actually receive, tagged with the source it came from so the
+// merge loop can pull that source's next row once this one is sent.
+type replayItem struct {
+	ts     time.Time
+	msg    Message
+	source *replaySource
+}
+
+// replayHeap orders queued items by timestamp so ticks from different
+// symbols and event types interleave in the order they originally occurred,
+// regardless of which source produced them.
+type replayHeap []replayItem
+
+func (h replayHeap) Len() int            { return len(h) }
+func (h replayHeap) Less(i, j int) bool  { return h[i].ts.Before(h[j].ts) }
+func (h replayHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *replayHeap) Push(x interface{}) { *h = append(*h, x.(replayItem)) }
+func (h *replayHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// replaySource pages one (symbol, event type) pair out of TimescaleDB,
+// buffering one page at a time and advancing its cursor past the last row
+// returned so the next page picks up where it left off.
+type replaySource struct {
+	symbol  string
+	kind    string // "trade", "quote", or "aggregate"
+	channel string
+	cursor  time.Time
+	to      time.Time
+	buf     []replayItem
+	done    bool
+}
+
+func (s *replaySource) fetchPage(ctx context.Context) error {
+	switch s.kind {
+	case "trade":
+		rows, err := ReadTradesRange(ctx, s.symbol, s.cursor, s.to, replayPageSize, 0)
+		if err != nil {
+			return fmt.Errorf("replay: read trades for %s: %w", s.symbol, err)
+		}
+		for _, r := range rows {
+			s.buf = append(s.buf, replayItem{ts: r.Timestamp, msg: tradeRowMessage(r, s.channel), source: s})
+		}
+		s.advance(len(rows), func(i int) time.Time { return rows[i].Timestamp })
+
+	case "quote":
+		rows, err := ReadQuotesRange(ctx, s.symbol, s.cursor, s.to, replayPageSize, 0)
+		if err != nil {
+			return fmt.Errorf("replay: read quotes for %s: %w", s.symbol, err)
+		}
+		for _, r := range rows {
+			s.buf = append(s.buf, replayItem{ts: r.Timestamp, msg: quoteRowMessage(r, s.channel), source: s})
+		}
+		s.advance(len(rows), func(i int) time.Time { return rows[i].Timestamp })
+
+	case "aggregate":
+		rows, err := ReadAggregatesRange(ctx, s.symbol, s.cursor, s.to, replayPageSize, 0)
+		if err != nil {
+			return fmt.Errorf("replay: read aggregates for %s: %w", s.symbol, err)
+		}
+		for _, r := range rows {
+			s.buf = append(s.buf, replayItem{ts: r.Timestamp, msg: aggregateRowMessage(r, s.channel), source: s})
+		}
+		s.advance(len(rows), func(i int) time.Time { return rows[i].Timestamp })
+	}
+	return nil
+}
+
+// advance marks the source exhausted once a page comes back short of a full
+// page, or else moves the cursor just past the last row's timestamp so the
+// next fetchPage doesn't re-read it.
+func (s *replaySource) advance(rowCount int, lastTimestamp func(i int) time.Time) {
+	if rowCount < replayPageSize {
+		s.done = true
+	}
+	if rowCount > 0 {
+		s.cursor = lastTimestamp(rowCount - 1).Add(time.Nanosecond)
+	}
+}
+
+// nextItem returns this source's next item in timestamp order, paging in a
+// fresh page if the current one is exhausted. ok is false once the source
+// has no more rows before its "to" bound.
+func (s *replaySource) nextItem(ctx context.Context) (item replayItem, ok bool, err error) {
+	if len(s.buf) == 0 && !s.done {
+		if err := s.fetchPage(ctx); err != nil {
+			return replayItem{}, false, err
+		}
+	}
+	if len(s.buf) == 0 {
+		return replayItem{}, false, nil
+	}
+	item, s.buf = s.buf[0], s.buf[1:]
+	return item, true, nil
+}
+
+// buildReplayHeap opens one replaySource per (symbol, event type) pair -
+// every type when channel doesn't name a specific one (e.g. "market-data"),
+// which is the case the merge heap matters most for - and seeds the heap
+// with each source's first row.
+func buildReplayHeap(ctx context.Context, symbols []string, channel string, from, to time.Time) (*replayHeap, error) {
+	kinds := []string{"trade", "quote", "aggregate"}
+	switch {
+	case strings.Contains(channel, "trade"):
+		kinds = []string{"trade"}
+	case strings.Contains(channel, "quote"):
+		kinds = []string{"quote"}
+	case strings.Contains(channel, "aggregate"):
+		kinds = []string{"aggregate"}
+	}
+
+	h := &replayHeap{}
+	heap.Init(h)
+	for _, symbol := range symbols {
+		for _, kind := range kinds {
+			source := &replaySource{symbol: symbol, kind: kind, channel: channel, cursor: from, to: to}
+			item, ok, err := source.nextItem(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				heap.Push(h, item)
+			}
+		}
+	}
+	return h, nil
+}
+
+func tradeRowMessage(r TradeRow, channel string) Message {
+	return Message{
+		Type:      "market-data",
+		Channel:   channel,
+		Data:      r,
+		Timestamp: r.Timestamp.UnixMilli(),
+		Symbols:   []string{r.Symbol},
+		Metadata:  map[string]interface{}{"source": "replay", "event_type": "T"},
+	}
+}
+
+func quoteRowMessage(r QuoteRow, channel string) Message {
+	return Message{
+		Type:      "market-data",
+		Channel:   channel,
+		Data:      r,
+		Timestamp: r.Timestamp.UnixMilli(),
+		Symbols:   []string{r.Symbol},
+		Metadata:  map[string]interface{}{"source": "replay", "event_type": "Q"},
+	}
+}
+
+func aggregateRowMessage(r AggregateRow, channel string) Message {
+	return Message{
+		Type:      "market-data",
+		Channel:   channel,
+		Data:      r,
+		Timestamp: r.Timestamp.UnixMilli(),
+		Symbols:   []string{r.Symbol},
+		Metadata:  map[string]interface{}{"source": "replay", "event_type": "A"},
+	}
+}
+
+// ================================================
+// REPLAY SESSION CONTROL (pause / resume / seek)
+// ================================================
+
+// replaySession is the pause/resume/seek control surface for one client's
+// active replay goroutine.
+type replaySession struct {
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	paused   bool
+	resumeCh chan struct{}
+	seekTo   *time.Time
+}
+
+func newReplaySession(cancel context.CancelFunc) *replaySession {
+	return &replaySession{cancel: cancel, resumeCh: make(chan struct{})}
+}
+
+func (s *replaySession) pause() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = true
+}
+
+func (s *replaySession) resume() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.paused {
+		s.paused = false
+		close(s.resumeCh)
+		s.resumeCh = make(chan struct{})
+	}
+}
+
+func (s *replaySession) seek(to time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seekTo = &to
+}
+
+// waitIfPaused blocks the replay loop while paused, returning false if ctx
+// is canceled (a new replay superseded this one, or the client disconnected)
+// before resume is called.
+func (s *replaySession) waitIfPaused(ctx context.Context) bool {
+	s.mu.Lock()
+	paused, ch := s.paused, s.resumeCh
+	s.mu.Unlock()
+	if !paused {
+		return true
+	}
+	select {
+	case <-ch:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// takeSeek returns and clears a pending seek target, if any.
+func (s *replaySession) takeSeek() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seekTo == nil {
+		return time.Time{}, false
+	}
+	target := *s.seekTo
+	s.seekTo = nil
+	return target, true
+}
+
+// ================================================
+// CLIENT ENTRY POINTS
+// ================================================
+
+// startReplay cancels any replay already running for c and starts a new one
+// from req.
+func (c *Client) startReplay(req SubscriptionRequest) {
+	c.mu.Lock()
+	if c.replay != nil {
+		c.replay.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	session := newReplaySession(cancel)
+	c.replay = session
+	c.mu.Unlock()
+
+	go c.runReplay(ctx, session, req)
+}
+
+// controlReplay applies a "pause"/"resume"/"seek" request to c's active
+// replay, if any. A seek's target comes from req.From, reusing the same
+// field a "replay" request uses for its range start.
+func (c *Client) controlReplay(cmd string, req SubscriptionRequest) {
+	c.mu.RLock()
+	session := c.replay
+	c.mu.RUnlock()
+	if session == nil {
+		return
+	}
+
+	switch cmd {
+	case "pause":
+		session.pause()
+	case "resume":
+		session.resume()
+	case "seek":
+		session.seek(time.UnixMilli(req.From))
+	}
+}
+
+// replayOutcome reports why runReplaySegment returned.
+type replayOutcome int
+
+const (
+	replayFinished replayOutcome = iota
+	replaySeeked
+	replayCanceled
+)
+
+// runReplay pages req's time range into the client's outbound queue at
+// req.Speed, emitting replay-start/replay-progress/replay-done control
+// frames around the ticks themselves.
+func (c *Client) runReplay(ctx context.Context, session *replaySession, req SubscriptionRequest) {
+	from := time.UnixMilli(req.From)
+	to := time.Now()
+	if req.To > 0 {
+		to = time.UnixMilli(req.To)
+	}
+	speed := req.Speed
+	if speed <= 0 {
+		speed = 1.0
+	}
+
+	c.sendMessage(Message{Type: "replay-start", Channel: req.Channel, Timestamp: time.Now().UnixMilli()})
+
+	total := to.Sub(from)
+	if total <= 0 {
+		c.sendMessage(Message{Type: "replay-done", Channel: req.Channel, Timestamp: time.Now().UnixMilli()})
+		return
+	}
+
+	// sessionFrom stays fixed at the original session start, independent of
+	// any later seeks, so runReplaySegment's pct reports progress through
+	// the whole replay window rather than resetting near zero after a seek.
+	sessionFrom := from
+
+	for {
+		outcome, seekFrom, err := c.runReplaySegment(ctx, session, req, sessionFrom, from, to, speed, total)
+		if err != nil {
+			c.sendMessage(Message{
+				Type:      "error",
+				Channel:   req.Channel,
+				Data:      map[string]string{"reason": err.Error()},
+				Timestamp: time.Now().UnixMilli(),
+			})
+			return
+		}
+
+		switch outcome {
+		case replayCanceled:
+			return
+		case replaySeeked:
+			from = seekFrom
+			continue
+		case replayFinished:
+			c.sendMessage(Message{Type: "replay-done", Channel: req.Channel, Timestamp: time.Now().UnixMilli()})
+			return
+		}
+	}
+}
+
+// runReplaySegment drains the merge heap for [from, to) onto c's outbound
+// queue, honoring pause/seek/cancellation between ticks. sessionFrom is the
+// original replay session's start (fixed across seeks), used only for the
+// reported progress percentage; from/to bound this segment's heap window and
+// move to the seek target on each new segment.
+func (c *Client) runReplaySegment(ctx context.Context, session *replaySession, req SubscriptionRequest, sessionFrom, from, to time.Time, speed float64, total time.Duration) (replayOutcome, time.Time, error) {
+	h, err := buildReplayHeap(ctx, req.Symbols, req.Channel, from, to)
+	if err != nil {
+		return replayFinished, time.Time{}, err
+	}
+
+	var wallStart, replayStart time.Time
+	first := true
+
+	for h.Len() > 0 {
+		if ctx.Err() != nil {
+			return replayCanceled, time.Time{}, nil
+		}
+		if !session.waitIfPaused(ctx) {
+			return replayCanceled, time.Time{}, nil
+		}
+		if seekTo, ok := session.takeSeek(); ok {
+			return replaySeeked, seekTo, nil
+		}
+
+		item := heap.Pop(h).(replayItem)
+
+		if first {
+			wallStart, replayStart = time.Now(), item.ts
+			first = false
+		} else if wait := time.Duration(float64(item.ts.Sub(replayStart))/speed) - time.Since(wallStart); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return replayCanceled, time.Time{}, nil
+			}
+		}
+
+		c.sendMessage(item.msg)
+
+		pct := float64(item.ts.Sub(sessionFrom)) / float64(total) * 100
+		if pct > 100 {
+			pct = 100
+		}
+		c.sendMessage(Message{
+			Type:      "replay-progress",
+			Channel:   req.Channel,
+			Data:      map[string]float64{"pct": pct},
+			Timestamp: time.Now().UnixMilli(),
+		})
+
+		next, ok, err := item.source.nextItem(ctx)
+		if err != nil {
+			return replayFinished, time.Time{}, err
+		}
+		if ok {
+			heap.Push(h, next)
+		}
+	}
+
+	return replayFinished, time.Time{}, nil
+}
@@ -0,0 +1,146 @@
+// ================================================
+// SERVER-SENT EVENTS TRANSPORT
+// ================================================
+// Corporate proxies and mobile background fetches often can't hold a
+// WebSocket open but handle a long-lived HTTP response fine, so /sse offers
+// the same channel/symbol subscription model over text/event-stream. SSE is
+// one-directional (server -> client only), so there's no read loop: the
+// subscription itself comes from the initial query string instead of a
+// post-connect "subscribe" frame, and reconnecting with Last-Event-ID
+// replays any history missed while disconnected.
+// ================================================
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sseTransport writes each queued frame as one SSE "data:" event, forcing
+// the JSON codec since event-stream payloads are plain UTF-8 text.
+// Monotonic ids let a reconnecting client resume via Last-Event-ID.
+type sseTransport struct {
+	w       *bufio.Writer
+	flusher http.Flusher
+	done    chan struct{}
+	nextID  int64
+}
+
+func newSSETransport(w *bufio.Writer, flusher http.Flusher) *sseTransport {
+	return &sseTransport{w: w, flusher: flusher, done: make(chan struct{})}
+}
+
+// WriteFrames writes each item as its own SSE event. isBinary is always
+// false here - resolveCodec forces JSON for SSE connections - but the
+// parameter stays to satisfy Transport.
+func (t *sseTransport) WriteFrames(items []outboundItem, isBinary bool) error {
+	for _, item := range items {
+		t.nextID++
+		if _, err := fmt.Fprintf(t.w, "id: %d\ndata: %s\n\n", t.nextID, item.data); err != nil {
+			return err
+		}
+	}
+	if err := t.w.Flush(); err != nil {
+		return err
+	}
+	t.flusher.Flush()
+	return nil
+}
+
+// Close signals handleSSE's blocking select to return, ending the response.
+// Safe to call more than once.
+func (t *sseTransport) Close() error {
+	select {
+	case <-t.done:
+	default:
+		close(t.done)
+	}
+	return nil
+}
+
+// handleSSE streams channel to one subscriber as Server-Sent Events:
+//
+//	GET /sse/{channel}?symbols=AAPL,MSFT&token=...
+//
+// Auth follows the same rules as /ws (see authenticateWebSocket): no
+// WS_JWKS_URL configured means auth is disabled.
+func handleSSE(w http.ResponseWriter, r *http.Request) {
+	channel := strings.TrimPrefix(r.URL.Path, "/sse/")
+	if channel == "" {
+		http.Error(w, "channel is required", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := authenticateWebSocket(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	var symbols []string
+	if raw := r.URL.Query().Get("symbols"); raw != "" {
+		symbols = strings.Split(raw, ",")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	transport := newSSETransport(bufio.NewWriter(w), flusher)
+
+	client := &Client{
+		transport:     transport,
+		queue:         newOutboundQueue(),
+		budget:        newWriteBudget(),
+		codec:         jsonCodec{},
+		compressor:    identityCompressor{},
+		claims:        claims,
+		subscriptions: make(map[string]bool),
+		symbols:       make(map[string]int),
+		symbolsByKey:  make(map[string][]string),
+		id:            generateClientID(),
+		lastSeen:      time.Now(),
+	}
+
+	wsRegistry.Track(claims.JTI, transport)
+	registerClient(client)
+	defer func() {
+		wsRegistry.Untrack(claims.JTI, transport)
+		unregisterClient(client)
+		log.Printf("👋 SSE client disconnected: %s (Total: %d)", client.id, getClientCount())
+	}()
+
+	log.Printf("✅ New SSE client connected: %s (channel: %s, tenant: %s, total: %d)",
+		client.id, channel, client.claims.Tenant, getClientCount())
+
+	go client.writePump()
+
+	// Broker.History takes a count, not a cursor, so Last-Event-ID (the
+	// sequence number this client last saw) doubles as "how many events to
+	// replay" rather than a true resume-after point.
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		if n, err := strconv.Atoi(lastEventID); err == nil && n > 0 {
+			client.sendHistory(channel, n)
+		}
+	}
+	client.subscribe(channel, symbols)
+
+	select {
+	case <-transport.done:
+	case <-r.Context().Done():
+	}
+}
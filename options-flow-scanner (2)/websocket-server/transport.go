@@ -0,0 +1,138 @@
+// ================================================
+// TRANSPORT ABSTRACTION
+// ================================================
+// Not every client can hold a WebSocket open (mobile background, corporate
+// proxies, server-side subscribers). Client's subscribe/unsubscribe/
+// broadcast/auth/rate-limiting logic doesn't actually care how bytes reach
+// the peer, so that's pulled behind Transport - handleWebSocket, handleSSE,
+// and the gRPC MarketData service all build the same *Client with a
+// different Transport, and share one Broker, one set of Codecs, the same
+// wsauth claims/quota enforcement, and the same write budget.
+// ================================================
+
+package main
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"nexuralflow/websocket-server/pb"
+)
+
+// Transport delivers already-codec-encoded, already-compressed frames to
+// one connected peer and owns that peer's connection lifecycle. It
+// satisfies io.Closer so wsauth.Registry can track/revoke it like any other
+// connection.
+type Transport interface {
+	// WriteFrames delivers a batch of queued frames to the peer. WebSocket
+	// coalesces a batch into a single physical frame (see wsTransport);
+	// transports with no such concept just write each one in turn.
+	WriteFrames(items []outboundItem, isBinary bool) error
+
+	// Close terminates the underlying connection.
+	Close() error
+}
+
+// Pinger is implemented by transports with an idle keepalive primitive.
+// writePump's heartbeat ticker calls it when the transport supports it;
+// transports that don't (SSE, gRPC) simply skip the tick.
+type Pinger interface {
+	Ping() error
+}
+
+// ================================================
+// WEBSOCKET TRANSPORT
+// ================================================
+
+// wsTransport is the original transport this server shipped with - the
+// other two were added alongside it, not in place of it.
+type wsTransport struct {
+	conn *websocket.Conn
+}
+
+func (t *wsTransport) WriteFrames(items []outboundItem, isBinary bool) error {
+	frameType := websocket.TextMessage
+	if isBinary {
+		frameType = websocket.BinaryMessage
+	}
+
+	t.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	w, err := t.conn.NextWriter(frameType)
+	if err != nil {
+		return err
+	}
+
+	if frameType == websocket.BinaryMessage {
+		// Binary codecs have no natural message separator, so each item is
+		// length-prefixed within the shared frame.
+		var lenBuf [binary.MaxVarintLen64]byte
+		for _, item := range items {
+			n := binary.PutUvarint(lenBuf[:], uint64(len(item.data)))
+			w.Write(lenBuf[:n])
+			w.Write(item.data)
+		}
+	} else {
+		for i, item := range items {
+			if i > 0 {
+				w.Write([]byte{'\n'})
+			}
+			w.Write(item.data)
+		}
+	}
+
+	return w.Close()
+}
+
+func (t *wsTransport) Close() error {
+	t.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	t.conn.WriteMessage(websocket.CloseMessage, []byte{})
+	return t.conn.Close()
+}
+
+func (t *wsTransport) Ping() error {
+	t.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return t.conn.WriteMessage(websocket.PingMessage, nil)
+}
+
+// ================================================
+// GRPC TRANSPORT
+// ================================================
+
+// grpcTransport delivers frames over a MarketData.Subscribe server stream.
+// The client is always forced onto the protobuf codec (see grpc.go), so
+// each queued item is already a marshaled pb.Message - WriteFrames just
+// unmarshals it back out to call the stream's typed Send.
+type grpcTransport struct {
+	stream pb.MarketData_SubscribeServer
+	done   chan struct{}
+}
+
+func newGRPCTransport(stream pb.MarketData_SubscribeServer) *grpcTransport {
+	return &grpcTransport{stream: stream, done: make(chan struct{})}
+}
+
+func (t *grpcTransport) WriteFrames(items []outboundItem, isBinary bool) error {
+	for _, item := range items {
+		msg := new(pb.Message)
+		if err := msg.Unmarshal(item.data); err != nil {
+			return err
+		}
+		if err := t.stream.Send(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close signals grpc.go's recv loop to return, ending the RPC. Safe to call
+// more than once.
+func (t *grpcTransport) Close() error {
+	select {
+	case <-t.done:
+	default:
+		close(t.done)
+	}
+	return nil
+}
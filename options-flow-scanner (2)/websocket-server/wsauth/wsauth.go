@@ -0,0 +1,384 @@
+// ================================================
+// PER-CONNECTION JWT AUTH
+// ================================================
+// handleWebSocket used to upgrade any request whose Origin passed
+// CheckOrigin, with no notion of who the caller was or what plan they're
+// on. Every upgrade now requires a bearer token, verified by a pluggable
+// Verifier and attached to the Client as Claims so subscribe() can enforce
+// channel/symbol scope and writePump can rate-limit per plan. Registry
+// backs the admin revoke endpoint: revoking a token force-closes every
+// socket currently open under it.
+// ================================================
+
+package wsauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Claims is the per-connection identity and entitlement set a Verifier
+// extracts from a token.
+type Claims struct {
+	Subject         string
+	JTI             string
+	Tenant          string
+	Plan            string
+	AllowedChannels []string
+	MaxSymbols      int
+	MaxMsgsPerSec   int
+	ExpiresAt       time.Time
+}
+
+// AllowsChannel reports whether these claims permit subscribing to channel.
+// An empty AllowedChannels means unrestricted, so legacy tokens that predate
+// the channels claim keep working.
+func (c Claims) AllowsChannel(channel string) bool {
+	if len(c.AllowedChannels) == 0 {
+		return true
+	}
+	for _, allowed := range c.AllowedChannels {
+		if allowed == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// Verifier validates a raw bearer token and returns the Claims it carries.
+// Operators plug in whatever identity provider they run - JWKSVerifier below
+// covers the common Auth0/Okta/Keycloak case (RS256 against a JWKS
+// endpoint); a custom Verifier can wrap an introspection endpoint, a static
+// shared secret, or anything else, as long as it satisfies this interface.
+type Verifier interface {
+	Verify(ctx context.Context, rawToken string) (Claims, error)
+}
+
+// ExtractToken pulls a bearer token from the Authorization header or,
+// failing that, a ?token= query parameter. Browsers can't set arbitrary
+// headers on a WebSocket handshake, so the query fallback is the common
+// case for browser clients; server-to-server clients can use either.
+func ExtractToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimPrefix(h, "Bearer ")
+	}
+	return r.URL.Query().Get("token")
+}
+
+// ================================================
+// JWKS-BACKED RS256 VERIFIER
+// ================================================
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+// JWKSVerifier verifies RS256-signed JWTs against a remote JSON Web Key Set,
+// refreshing the key set on a TTL and transparently retrying once against a
+// fresh fetch when an unrecognized kid shows up (covers key rotation).
+type JWKSVerifier struct {
+	JWKSURL string
+	Refresh time.Duration
+	HTTP    *http.Client
+
+	mu        sync.RWMutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+// NewJWKSVerifier creates a JWKSVerifier that fetches keys from jwksURL, at
+// most once per refresh interval.
+func NewJWKSVerifier(jwksURL string, refresh time.Duration) *JWKSVerifier {
+	return &JWKSVerifier{
+		JWKSURL: jwksURL,
+		Refresh: refresh,
+		HTTP:    &http.Client{Timeout: 5 * time.Second},
+		keys:    make(map[string]*rsa.PublicKey),
+	}
+}
+
+// jwtHeader is the subset of the JOSE header this verifier cares about.
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// jwtPayload maps the standard and NexuralFlow-specific private claims.
+type jwtPayload struct {
+	Sub             string   `json:"sub"`
+	JTI             string   `json:"jti"`
+	Exp             int64    `json:"exp"`
+	Tenant          string   `json:"tenant"`
+	Plan            string   `json:"plan"`
+	AllowedChannels []string `json:"channels"`
+	MaxSymbols      int      `json:"max_symbols"`
+	MaxMsgsPerSec   int      `json:"max_msgs_per_sec"`
+}
+
+// Verify checks rawToken's RS256 signature against the JWKS and decodes its
+// claims. Implements Verifier.
+func (v *JWKSVerifier) Verify(ctx context.Context, rawToken string) (Claims, error) {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return Claims{}, fmt.Errorf("wsauth: malformed token")
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return Claims{}, fmt.Errorf("wsauth: decode header: %w", err)
+	}
+	var header jwtHeader
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return Claims{}, fmt.Errorf("wsauth: parse header: %w", err)
+	}
+	if header.Alg != "RS256" {
+		return Claims{}, fmt.Errorf("wsauth: unsupported alg %q", header.Alg)
+	}
+
+	key, err := v.keyFor(ctx, header.Kid)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, fmt.Errorf("wsauth: decode signature: %w", err)
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return Claims{}, fmt.Errorf("wsauth: signature verification failed: %w", err)
+	}
+
+	payloadJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Claims{}, fmt.Errorf("wsauth: decode payload: %w", err)
+	}
+	var payload jwtPayload
+	if err := json.Unmarshal(payloadJSON, &payload); err != nil {
+		return Claims{}, fmt.Errorf("wsauth: parse payload: %w", err)
+	}
+
+	expiresAt := time.Unix(payload.Exp, 0)
+	if payload.Exp != 0 && time.Now().After(expiresAt) {
+		return Claims{}, fmt.Errorf("wsauth: token expired")
+	}
+
+	return Claims{
+		Subject:         payload.Sub,
+		JTI:             payload.JTI,
+		Tenant:          payload.Tenant,
+		Plan:            payload.Plan,
+		AllowedChannels: payload.AllowedChannels,
+		MaxSymbols:      payload.MaxSymbols,
+		MaxMsgsPerSec:   payload.MaxMsgsPerSec,
+		ExpiresAt:       expiresAt,
+	}, nil
+}
+
+func (v *JWKSVerifier) keyFor(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	v.mu.RLock()
+	key, ok := v.keys[kid]
+	stale := time.Since(v.fetchedAt) > v.Refresh
+	v.mu.RUnlock()
+	if ok && !stale {
+		return key, nil
+	}
+
+	if err := v.refresh(ctx); err != nil {
+		if ok {
+			return key, nil // serve the stale key rather than fail an otherwise-valid token
+		}
+		return nil, err
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("wsauth: unknown key id %q", kid)
+}
+
+func (v *JWKSVerifier) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.JWKSURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("wsauth: fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var set jwkSet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("wsauth: decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.fetchedAt = time.Now()
+	v.mu.Unlock()
+	return nil
+}
+
+func (k jwk) publicKey() (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	eBytes, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+
+	e := 0
+	for _, b := range eBytes {
+		e = e<<8 | int(b)
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(nBytes), E: e}, nil
+}
+
+// ================================================
+// REVOCATION REGISTRY
+// ================================================
+
+// defaultRevokedTTL bounds how long a revoked JTI is remembered before
+// StartSweeper drops it. It's generous enough to outlive any token's JWT
+// exp, so a dropped entry could never have still backed a valid token.
+const defaultRevokedTTL = 24 * time.Hour
+
+// Registry tracks which tokens (by JTI) are currently backing an open
+// connection, and which JTIs have been revoked. The admin revoke endpoint
+// calls Revoke, which force-closes every connection open under that token
+// and rejects it on any future Verify-then-check.
+type Registry struct {
+	mu         sync.Mutex
+	revoked    map[string]time.Time
+	sockets    map[string]map[io.Closer]bool
+	revokedTTL time.Duration
+}
+
+// NewRegistry creates an empty Registry. Call StartSweeper to bound the
+// revoked set's memory on a long-running instance.
+func NewRegistry() *Registry {
+	return &Registry{
+		revoked:    make(map[string]time.Time),
+		sockets:    make(map[string]map[io.Closer]bool),
+		revokedTTL: defaultRevokedTTL,
+	}
+}
+
+// StartSweeper periodically drops revoked JTIs older than r.revokedTTL, so
+// routine token revocation on a long-running instance doesn't grow the
+// revoked set forever - a token revoked that long ago would already have
+// expired on its own JWT exp, so it could never be presented as valid again
+// regardless of whether Registry still remembers revoking it. Blocks until
+// ctx is canceled; run it in its own goroutine.
+func (r *Registry) StartSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.sweepRevoked()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *Registry) sweepRevoked() {
+	cutoff := time.Now().Add(-r.revokedTTL)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for jti, revokedAt := range r.revoked {
+		if revokedAt.Before(cutoff) {
+			delete(r.revoked, jti)
+		}
+	}
+}
+
+// IsRevoked reports whether jti has been revoked. Tokens with no JTI can't
+// be revoked and always report false.
+func (r *Registry) IsRevoked(jti string) bool {
+	if jti == "" {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.revoked[jti]
+	return ok
+}
+
+// Track associates conn with jti so a future Revoke(jti) closes it.
+func (r *Registry) Track(jti string, conn io.Closer) {
+	if jti == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sockets[jti] == nil {
+		r.sockets[jti] = make(map[io.Closer]bool)
+	}
+	r.sockets[jti][conn] = true
+}
+
+// Untrack removes conn from jti's tracked set, e.g. once the client
+// disconnects on its own.
+func (r *Registry) Untrack(jti string, conn io.Closer) {
+	if jti == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sockets[jti], conn)
+}
+
+// Revoke marks jti revoked and force-closes every connection currently
+// tracked under it, returning how many sockets were closed.
+func (r *Registry) Revoke(jti string) int {
+	r.mu.Lock()
+	conns := r.sockets[jti]
+	delete(r.sockets, jti)
+	r.revoked[jti] = time.Now()
+	r.mu.Unlock()
+
+	for conn := range conns {
+		conn.Close()
+	}
+	return len(conns)
+}